@@ -0,0 +1,215 @@
+package glox
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// NativeFunc is a builtin function implemented in Go. Builtins are used by
+// pointer so that they can be compared with isEqual. Errors returned by fn are
+// positioned at the call that caused them.
+type NativeFunc struct {
+	name  string
+	arity int
+	// optional is the number of trailing parameters that may be left out, in
+	// which case fn gets fewer args
+	optional int
+	fn       func(args []any) (any, error)
+}
+
+var _ Caller = &NativeFunc{}
+
+func (f *NativeFunc) Arity() int { return f.arity }
+
+func (f *NativeFunc) Call(env *Environment, args []any) any {
+	v, err := f.fn(args)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (f *NativeFunc) String() string {
+	return fmt.Sprintf("<builtin fn %s>", f.name)
+}
+
+var builtins = []*NativeFunc{
+	{name: "len", arity: 1, fn: builtinLen},
+	{name: "append", arity: 2, fn: builtinAppend},
+	{name: "pop", arity: 1, fn: builtinPop},
+	{name: "insert", arity: 3, fn: builtinInsert},
+	{name: "slice", arity: 3, fn: builtinSlice},
+	{name: "keys", arity: 1, fn: builtinKeys},
+	{name: "values", arity: 1, fn: builtinValues},
+	{name: "has", arity: 2, fn: builtinHas},
+	{name: "delete", arity: 2, fn: builtinDelete},
+	{name: "range", arity: 3, optional: 1, fn: builtinRange},
+}
+
+// prelude is the environment enclosing the global environment of every
+// program. It declares the standard library, so that programs can shadow
+// builtins with their own globals.
+var prelude = newPrelude()
+
+func newPrelude() *Environment {
+	env := NewEnvironment(nil)
+	env.Declare("clock", ClockFunc{})
+	for _, f := range builtins {
+		env.Declare(f.name, f)
+	}
+	return env
+}
+
+// usePrelude makes the prelude enclose the global environment of env
+func usePrelude(env *Environment) {
+	env.ancestor(-1).enclosing = prelude
+}
+
+// argError reports a builtin argument of the wrong type
+func argError(fn, expected string, v any) error {
+	return runtimeError(Pos{}, ErrorKindType, "%s: expected %s, got %s", fn, expected, typeName(v))
+}
+
+// builtinLen returns the number of elements in a list or map, or characters in
+// a string
+func builtinLen(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case *LoxList:
+		return float64(len(v.elements)), nil
+	case *LoxMap:
+		return float64(len(v.keys)), nil
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	}
+	return nil, argError("len", "a list, map or string", args[0])
+}
+
+// builtinAppend adds a value to the end of a list
+func builtinAppend(args []any) (any, error) {
+	list, ok := args[0].(*LoxList)
+	if !ok {
+		return nil, argError("append", "a list", args[0])
+	}
+	list.elements = append(list.elements, args[1])
+	return nil, nil
+}
+
+// builtinPop removes and returns the last element of a list
+func builtinPop(args []any) (any, error) {
+	list, ok := args[0].(*LoxList)
+	if !ok {
+		return nil, argError("pop", "a list", args[0])
+	}
+	if len(list.elements) == 0 {
+		return nil, runtimeError(Pos{}, ErrorKindIndex, "pop: cannot pop from an empty list")
+	}
+	last := list.elements[len(list.elements)-1]
+	list.elements = list.elements[:len(list.elements)-1]
+	return last, nil
+}
+
+// builtinInsert inserts a value before the given index. An index equal to the
+// list's length appends.
+func builtinInsert(args []any) (any, error) {
+	list, ok := args[0].(*LoxList)
+	if !ok {
+		return nil, argError("insert", "a list", args[0])
+	}
+	i, err := listIndex(args[1], len(list.elements)+1)
+	if err != nil {
+		return nil, err
+	}
+	list.elements = append(list.elements, nil)
+	copy(list.elements[i+1:], list.elements[i:])
+	list.elements[i] = args[2]
+	return nil, nil
+}
+
+// builtinSlice returns a new list of the elements from start up to but not
+// including end
+func builtinSlice(args []any) (any, error) {
+	list, ok := args[0].(*LoxList)
+	if !ok {
+		return nil, argError("slice", "a list", args[0])
+	}
+	start, err := listIndex(args[1], len(list.elements)+1)
+	if err != nil {
+		return nil, err
+	}
+	end, err := listIndex(args[2], len(list.elements)+1)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		return nil, runtimeError(Pos{}, ErrorKindIndex, "slice: end %d is before start %d", end, start)
+	}
+	elements := make([]any, end-start)
+	copy(elements, list.elements[start:end])
+	return &LoxList{elements: elements}, nil
+}
+
+// builtinKeys returns a list of a map's keys in insertion order
+func builtinKeys(args []any) (any, error) {
+	m, ok := args[0].(*LoxMap)
+	if !ok {
+		return nil, argError("keys", "a map", args[0])
+	}
+	keys := make([]any, len(m.keys))
+	copy(keys, m.keys)
+	return &LoxList{elements: keys}, nil
+}
+
+// builtinValues returns a list of a map's values in key insertion order
+func builtinValues(args []any) (any, error) {
+	m, ok := args[0].(*LoxMap)
+	if !ok {
+		return nil, argError("values", "a map", args[0])
+	}
+	values := make([]any, 0, len(m.keys))
+	for _, key := range m.keys {
+		values = append(values, m.entries[key])
+	}
+	return &LoxList{elements: values}, nil
+}
+
+// builtinHas reports whether a map contains a key
+func builtinHas(args []any) (any, error) {
+	m, ok := args[0].(*LoxMap)
+	if !ok {
+		return nil, argError("has", "a map", args[0])
+	}
+	if err := checkMapKey(args[1]); err != nil {
+		return nil, err
+	}
+	_, ok = m.entries[args[1]]
+	return ok, nil
+}
+
+// builtinDelete removes a key from a map, reporting whether it was present
+func builtinDelete(args []any) (any, error) {
+	m, ok := args[0].(*LoxMap)
+	if !ok {
+		return nil, argError("delete", "a map", args[0])
+	}
+	if err := checkMapKey(args[1]); err != nil {
+		return nil, err
+	}
+	return m.Delete(args[1]), nil
+}
+
+// builtinRange returns the numbers from start up to but not including end, in
+// increments of step, which may be negative to count down and defaults to 1
+func builtinRange(args []any) (any, error) {
+	bounds := []float64{0, 0, 1}
+	for i, arg := range args {
+		n, ok := arg.(float64)
+		if !ok {
+			return nil, argError("range", "numbers", arg)
+		}
+		bounds[i] = n
+	}
+	if bounds[2] == 0 {
+		return nil, runtimeError(Pos{}, ErrorKindError, "range: step must not be zero")
+	}
+	return &LoxRange{start: bounds[0], end: bounds[1], step: bounds[2]}, nil
+}
@@ -0,0 +1,68 @@
+package glox
+
+import "fmt"
+
+type LoxClass struct {
+	name       string
+	superclass *LoxClass
+	methods    map[string]*DefinedFunc
+}
+
+var _ Caller = &LoxClass{}
+
+// findMethod looks up a method on the class, then up its superclass chain
+func (c *LoxClass) findMethod(name string) (*DefinedFunc, bool) {
+	if method, ok := c.methods[name]; ok {
+		return method, true
+	}
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
+	return nil, false
+}
+
+// Arity is the arity of the class's initializer, or 0 if it has none
+func (c *LoxClass) Arity() int {
+	if initializer, ok := c.findMethod("init"); ok {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+// Call creates a new instance of the class and runs its initializer, if any
+func (c *LoxClass) Call(env *Environment, args []any) any {
+	instance := &LoxInstance{class: c, fields: map[string]any{}}
+	if initializer, ok := c.findMethod("init"); ok {
+		initializer.bind(instance).Call(env, args)
+	}
+	return instance
+}
+
+func (c *LoxClass) String() string {
+	return fmt.Sprintf("<class %s>", c.name)
+}
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]any
+}
+
+// Get returns the field with the given name, falling back to a method bound to
+// the instance. Fields shadow methods.
+func (i *LoxInstance) Get(name Token) any {
+	if v, ok := i.fields[name.Lexeme]; ok {
+		return v
+	}
+	if method, ok := i.class.findMethod(name.Lexeme); ok {
+		return method.bind(i)
+	}
+	panic(tokenError(name, ErrorKindName, "undefined property '%s'", name.Lexeme))
+}
+
+func (i *LoxInstance) Set(name Token, v any) {
+	i.fields[name.Lexeme] = v
+}
+
+func (i *LoxInstance) String() string {
+	return fmt.Sprintf("<%s instance>", i.class.name)
+}
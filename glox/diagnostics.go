@@ -0,0 +1,231 @@
+package glox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severities of a Diagnostic
+const (
+	SeverityError = "error"
+)
+
+// Codes of diagnostics for static errors. Runtime errors use their kind, such
+// as TypeError, as their code.
+const (
+	CodeScan    = "ScanError"
+	CodeSyntax  = "SyntaxError"
+	CodeResolve = "ResolveError"
+	CodeError   = "Error"
+)
+
+// Diagnostic is an error prepared for reporting to a user, with the position
+// in the source it refers to
+type Diagnostic struct {
+	Severity string
+	Code     string
+	Message  string
+	File     string
+	// Pos is only meaningful when Positioned is set. Errors such as a missing
+	// file have no position.
+	Pos        Pos
+	Positioned bool
+	// Hint is an optional suggestion for fixing the error
+	Hint string
+	// Related are other places involved in the error, such as the declaration
+	// of a constant that was assigned to
+	Related []Related
+	// Trace is the Lox call stack of a runtime error, innermost first
+	Trace []Frame
+}
+
+// Related is a location related to a Diagnostic
+type Related struct {
+	Message string
+	// File is empty for a location in the same file as the diagnostic
+	File string
+	Pos  Pos
+}
+
+// Diagnostics returns a diagnostic for each error in err, which may be a
+// joined error or ParseErrors. file is the path of the program that failed,
+// which errors are reported in unless they carry their own.
+func Diagnostics(err error, file string) []Diagnostic {
+	if merr, ok := err.(*ModuleError); ok {
+		related := make([]Related, 0, len(merr.Related))
+		for _, r := range merr.Related {
+			if r.File == "" {
+				r.File = file
+			}
+			related = append(related, r)
+		}
+		diags := Diagnostics(merr.Err, merr.File)
+		for i := range diags {
+			diags[i].Related = append(diags[i].Related, related...)
+		}
+		return diags
+	}
+	var parseErrs ParseErrors
+	if errors.As(err, &parseErrs) {
+		diags := make([]Diagnostic, 0, len(parseErrs))
+		for _, perr := range parseErrs {
+			diags = append(diags, newDiagnostic(CodeSyntax, perr.Message, file, perr.Pos, perr.Hint, nil))
+		}
+		return diags
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		diags := []Diagnostic{}
+		for _, err := range joined.Unwrap() {
+			diags = append(diags, Diagnostics(err, file)...)
+		}
+		return diags
+	}
+	switch e := err.(type) {
+	case *ScanError:
+		return []Diagnostic{newDiagnostic(CodeScan, e.Message, file, e.Pos, "", nil)}
+	case *ResolveError:
+		return []Diagnostic{newDiagnostic(CodeResolve, e.Message, file, e.Pos, "", e.Related)}
+	case *RuntimeError:
+		if e.File != "" {
+			file = e.File
+		}
+		diag := newDiagnostic(e.Kind, e.Message, file, e.Pos, e.Hint, e.Related)
+		diag.Trace = e.Trace
+		return []Diagnostic{diag}
+	}
+	return []Diagnostic{{Severity: SeverityError, Code: CodeError, Message: err.Error(), File: file}}
+}
+
+func newDiagnostic(code, message, file string, pos Pos, hint string, related []Related) Diagnostic {
+	return Diagnostic{
+		Severity:   SeverityError,
+		Code:       code,
+		Message:    message,
+		File:       file,
+		Pos:        pos,
+		Positioned: true,
+		Hint:       hint,
+		Related:    related,
+	}
+}
+
+// ANSI escape sequences used when rendering in color
+const (
+	colorReset = "\x1b[0m"
+	colorError = "\x1b[1;31m"
+	colorFrame = "\x1b[1;34m"
+	colorHint  = "\x1b[1;36m"
+)
+
+// Render writes the diagnostic, showing the offending line of source, the
+// contents of its file, with the span of the error underlined:
+//
+//	error[NameError]: undefined variable 'cout'
+//	 --> main.lox:3:7
+//	  |
+//	3 | print cout;
+//	  |       ^^^^
+//	  = help: did you mean `count`?
+func (d Diagnostic) Render(w io.Writer, source []byte, color bool) {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+	fmt.Fprintf(w, "%s %s\n", paint(colorError, d.Severity+"["+d.Code+"]:"), d.Message)
+	if !d.Positioned {
+		if d.Hint != "" {
+			fmt.Fprintf(w, "%s %s\n", paint(colorHint, "help:"), d.Hint)
+		}
+		return
+	}
+
+	lines := strings.Split(string(source), "\n")
+	line := ""
+	if d.Pos.Line >= 0 && d.Pos.Line < len(lines) {
+		line = strings.TrimSuffix(lines[d.Pos.Line], "\r")
+	}
+	start := min(max(d.Pos.Start, 0), len(line))
+	end := min(max(d.Pos.End, start), len(line))
+	lineNum := fmt.Sprint(d.Pos.Line + 1)
+	gutter := strings.Repeat(" ", len(lineNum))
+
+	fmt.Fprintf(w, "%s%s %s:%d:%d\n", gutter, paint(colorFrame, "-->"), d.File, d.Pos.Line+1, utf8.RuneCountInString(line[:start])+1)
+	fmt.Fprintf(w, "%s %s\n", gutter, paint(colorFrame, "|"))
+	fmt.Fprintf(w, "%s %s %s\n", paint(colorFrame, lineNum), paint(colorFrame, "|"), line)
+	// Tabs are kept in the padding so the carets line up however they render
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, line[:start])
+	carets := strings.Repeat("^", max(utf8.RuneCountInString(line[start:end]), 1))
+	fmt.Fprintf(w, "%s %s %s%s\n", gutter, paint(colorFrame, "|"), padding, paint(colorError, carets))
+	for _, related := range d.Related {
+		file := related.File
+		if file == "" {
+			file = d.File
+		}
+		fmt.Fprintf(w, "%s %s %s %s: %s:%d\n", gutter, paint(colorFrame, "="), paint(colorHint, "note:"), related.Message, file, related.Pos.Line+1)
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(w, "%s %s %s %s\n", gutter, paint(colorFrame, "="), paint(colorHint, "help:"), d.Hint)
+	}
+}
+
+// didYouMean suggests the candidate closest to a misspelled name, or returns ""
+// if none is close enough to be a likely typo
+func didYouMean(name string, candidates []string) string {
+	// Allow one edit for every three characters, so short names don't match
+	// everything
+	best, bestDist := "", len(name)/3+1
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+	for _, candidate := range sorted {
+		if candidate == name {
+			continue
+		}
+		if dist := editDistance(name, candidate); dist < bestDist {
+			best, bestDist = candidate, dist
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("did you mean `%s`?", best)
+}
+
+// editDistance is the number of single character insertions, deletions,
+// substitutions and adjacent transpositions needed to turn a into b
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// dist[i][j] is the distance between the first i runes of s and the first
+	// j runes of t
+	dist := make([][]int, len(s)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(t)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			dist[i][j] = min(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				dist[i][j] = min(dist[i][j], dist[i-2][j-2]+1)
+			}
+		}
+	}
+	return dist[len(s)][len(t)]
+}
@@ -0,0 +1,108 @@
+package glox
+
+import (
+	"strings"
+	"testing"
+)
+
+// diagnose runs source and renders the diagnostics for the error it fails with
+func diagnose(t *testing.T, source string) string {
+	t.Helper()
+	tokens, err := NewScanner([]byte(source)).ScanTokens()
+	if err == nil {
+		err = NewParser(tokens).Execute(NewEnvironment(nil))
+	}
+	if err == nil {
+		t.Fatalf("Expected %q to fail", source)
+	}
+	builder := &strings.Builder{}
+	for _, diag := range Diagnostics(err, "test.lox") {
+		diag.Render(builder, []byte(source), false)
+	}
+	return builder.String()
+}
+
+func TestRenderDiagnostics(t *testing.T) {
+	tests := map[string]string{
+		"var count = 1;\nprint cout;": `error[NameError]: undefined variable 'cout'
+ --> test.lox:2:7
+  |
+2 | print cout;
+  |       ^^^^
+  = help: did you mean ` + "`count`" + `?
+`,
+		"\tprint 1 +;": `error[SyntaxError]: expected expression, got ';'
+ --> test.lox:1:11
+  |
+1 | 	print 1 +;
+  | 	         ^
+`,
+		"retrun 1;\nprint (1;": `error[SyntaxError]: expected ';' after expression, got '1'
+ --> test.lox:1:8
+  |
+1 | retrun 1;
+  |        ^
+  = help: did you mean ` + "`return`" + `?
+error[SyntaxError]: expected ')' after expression, got ';'
+ --> test.lox:2:9
+  |
+2 | print (1;
+  |         ^
+`,
+		"var s = \"é\" + nill;": `error[NameError]: undefined variable 'nill'
+ --> test.lox:1:15
+  |
+1 | var s = "é" + nill;
+  |               ^^^^
+  = help: did you mean ` + "`nil`" + `?
+`,
+		"{ var a = 1; a = 2; b = 3; }": `error[NameError]: undefined variable 'b'
+ --> test.lox:1:21
+  |
+1 | { var a = 1; a = 2; b = 3; }
+  |                     ^
+`,
+		"const a = 1;\na = 2;": `error[ResolveError]: cannot assign to constant 'a'
+ --> test.lox:2:1
+  |
+2 | a = 2;
+  | ^
+  = note: constant declared here: test.lox:1
+`,
+		"print (1 +\n  2\n": `error[SyntaxError]: expected ')' after expression, got end of file
+ --> test.lox:2:4
+  |
+2 |   2
+  |    ^
+`,
+		"var a = 1 @ 2;": `error[ScanError]: unexpected character: @
+ --> test.lox:1:11
+  |
+1 | var a = 1 @ 2;
+  |           ^
+`,
+	}
+	for source, expected := range tests {
+		if rendered := diagnose(t, source); rendered != expected {
+			t.Errorf("Expected for %q:\n%s\ngot:\n%s", source, expected, rendered)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	candidates := []string{"count", "counter", "total", "print", "x"}
+	tests := map[string]string{
+		"cout":   "did you mean `count`?",
+		"coutn":  "did you mean `count`?",
+		"countr": "did you mean `count`?",
+		"ttoal":  "did you mean `total`?",
+		"y":      "",
+		"zzzzz":  "",
+		"count":  "",
+	}
+	for name, expected := range tests {
+		if hint := didYouMean(name, candidates); hint != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, name, hint)
+		}
+	}
+}
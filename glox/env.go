@@ -1,10 +1,14 @@
 package glox
 
-import "fmt"
-
 type Environment struct {
 	enclosing *Environment
 	vars      map[string]any
+	// consts holds the declaration position of each binding that cannot be
+	// assigned. It is nil until a constant is declared.
+	consts map[string]Pos
+	// module is set on the global environment of each file, and is nil on
+	// all other environments
+	module *Module
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
@@ -28,7 +32,31 @@ func (e *Environment) Get(name string) (any, bool) {
 func (e *Environment) Declare(name string, val any) error {
 	_, ok := e.vars[name]
 	if ok {
-		return fmt.Errorf("redeclaration of var %s", name)
+		return runtimeError(Pos{}, ErrorKindName, "redeclaration of var %s", name)
+	}
+	e.vars[name] = val
+	return nil
+}
+
+// DeclareConst declares a binding that Set and SetAt refuse to change. pos
+// is the declaration's position, for errors about assigning to it.
+func (e *Environment) DeclareConst(name string, val any, pos Pos) error {
+	if err := e.Declare(name, val); err != nil {
+		return err
+	}
+	if e.consts == nil {
+		e.consts = map[string]Pos{}
+	}
+	e.consts[name] = pos
+	return nil
+}
+
+// assign changes an existing binding in this environment
+func (e *Environment) assign(name string, val any) error {
+	if pos, ok := e.consts[name]; ok {
+		err := runtimeError(Pos{}, ErrorKindName, "cannot assign to constant '%s'", name)
+		err.Related = []Related{{Message: "constant declared here", Pos: pos}}
+		return err
 	}
 	e.vars[name] = val
 	return nil
@@ -37,11 +65,58 @@ func (e *Environment) Declare(name string, val any) error {
 func (e *Environment) Set(name string, val any) error {
 	_, ok := e.vars[name]
 	if ok {
-		e.vars[name] = val
-		return nil
+		return e.assign(name, val)
 	}
 	if e.enclosing == nil {
-		return fmt.Errorf("unknown var %s", name)
+		return runtimeError(Pos{}, ErrorKindName, "undefined variable '%s'", name)
 	}
 	return e.enclosing.Set(name, val)
 }
+
+// names returns every name visible from this environment
+func (e *Environment) names() []string {
+	names := []string{}
+	for env := e; env != nil; env = env.enclosing {
+		for name := range env.vars {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ancestor returns the environment depth levels up the enclosing chain. A
+// negative depth returns the global environment, the outermost one below the
+// prelude.
+func (e *Environment) ancestor(depth int) *Environment {
+	env := e
+	if depth < 0 {
+		for env.enclosing != nil && env.enclosing != prelude {
+			env = env.enclosing
+		}
+		return env
+	}
+	for i := 0; i < depth; i++ {
+		env = env.enclosing
+	}
+	return env
+}
+
+// GetAt gets a variable from the environment depth levels up the chain, as
+// determined by the Resolver. Globals fall back to builtins in the prelude.
+func (e *Environment) GetAt(depth int, name string) (any, bool) {
+	v, ok := e.ancestor(depth).vars[name]
+	if !ok && depth < 0 {
+		v, ok = prelude.vars[name]
+	}
+	return v, ok
+}
+
+// SetAt sets a variable in the environment depth levels up the chain, as
+// determined by the Resolver.
+func (e *Environment) SetAt(depth int, name string, val any) error {
+	env := e.ancestor(depth)
+	if _, ok := env.vars[name]; !ok {
+		return runtimeError(Pos{}, ErrorKindName, "undefined variable '%s'", name)
+	}
+	return env.assign(name, val)
+}
@@ -0,0 +1,216 @@
+package glox
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Kinds of RuntimeError, visible to Lox code as the kind property of a caught
+// error
+const (
+	ErrorKindError         = "Error"
+	ErrorKindType          = "TypeError"
+	ErrorKindName          = "NameError"
+	ErrorKindIndex         = "IndexError"
+	ErrorKindKey           = "KeyError"
+	ErrorKindArithmetic    = "ArithmeticError"
+	ErrorKindImport        = "ImportError"
+	ErrorKindStackOverflow = "StackOverflowError"
+)
+
+// RuntimeError is an error raised while executing a program. It is panicked to
+// unwind the interpreter, and can be caught by a try statement, where Lox code
+// sees it as an error value with message, kind, line and column properties.
+type RuntimeError struct {
+	Kind    string
+	Message string
+	// Token is the token the error is reported at, such as the operator of a
+	// failed binary expression. It is the zero Token for errors positioned at
+	// a whole expression, which only set Pos.
+	Token Token
+	Pos   Pos
+	// Trace is the stack of Lox calls the error unwound through, innermost
+	// first
+	Trace []Frame
+	// Hint is an optional suggestion for fixing the error
+	Hint string
+	// Related are other places involved in the error
+	Related []Related
+	// File is the path of the module whose code raised the error, if it was
+	// raised in a function from a module other than the one being run
+	File string
+}
+
+// runtimeError creates a RuntimeError of the given kind at pos
+func runtimeError(pos Pos, kind, format string, args ...any) *RuntimeError {
+	return &RuntimeError{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+		Pos:     pos,
+	}
+}
+
+// tokenError creates a RuntimeError of the given kind at token
+func tokenError(token Token, kind, format string, args ...any) *RuntimeError {
+	err := runtimeError(token.Pos, kind, format, args...)
+	err.Token = token
+	return err
+}
+
+// atPos returns err as a RuntimeError positioned at pos. Helpers shared
+// between builtins and expressions, such as listIndex, return errors without a
+// meaningful position for their caller to place.
+func atPos(err error, pos Pos) *RuntimeError {
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) {
+		return runtimeError(pos, ErrorKindError, "%s", err)
+	}
+	positioned := *rerr
+	positioned.Token = Token{}
+	positioned.Pos = pos
+	return &positioned
+}
+
+// atToken is atPos for errors caused by a particular token
+func atToken(err error, token Token) *RuntimeError {
+	rerr := atPos(err, token.Pos)
+	rerr.Token = token
+	return rerr
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Kind, e.Message, e.Pos)
+}
+
+// String formats the error as Lox code sees it when printing a caught error
+func (e *RuntimeError) String() string {
+	return e.Kind + ": " + e.Message
+}
+
+// Get returns a property of the error value
+func (e *RuntimeError) Get(name Token) any {
+	switch name.Lexeme {
+	case "message":
+		return e.Message
+	case "kind":
+		return e.Kind
+	case "line":
+		return float64(e.Pos.Line + 1)
+	case "column":
+		return float64(e.Pos.Start + 1)
+	}
+	panic(tokenError(name, ErrorKindName, "undefined property '%s' on error", name.Lexeme))
+}
+
+// ParseError is a syntax error. Found is the token where the parser detected
+// it, and Expected is the type of token the parser needed there, or
+// TokenTypeNone when it needed something more general, such as an expression.
+type ParseError struct {
+	Message  string
+	Pos      Pos
+	Expected TokenType
+	Found    Token
+	// Hint is an optional suggestion for fixing the error
+	Hint string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Pos)
+}
+
+// ParseErrors is every syntax error in a program, in source order
+type ParseErrors []ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// ModuleError is an error that stopped an imported module from loading, such
+// as a syntax error in its source. Err is the module's own error, reported in
+// File, and Related holds the import statements that led to the module,
+// innermost first.
+type ModuleError struct {
+	File    string
+	Err     error
+	Related []Related
+}
+
+func (e *ModuleError) Error() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "error in module %s: %s", e.File, e.Err)
+	for _, related := range e.Related {
+		fmt.Fprintf(builder, "\n\timported by %s (%s)", related.File, related.Pos)
+	}
+	return builder.String()
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// importError returns the error as a try statement catches it: an ImportError
+// at the import statement that was running, with the import chain related
+func (e *ModuleError) importError() *RuntimeError {
+	inner := e.Err.Error()
+	if rerr, ok := e.Err.(*RuntimeError); ok {
+		inner = rerr.String()
+	}
+	err := runtimeError(Pos{}, ErrorKindImport, "error in module %s: %s", e.File, inner)
+	if len(e.Related) > 0 {
+		site := e.Related[len(e.Related)-1]
+		err.Pos, err.File = site.Pos, site.File
+	}
+	err.Related = e.Related
+	return err
+}
+
+// thrownValue is panicked by a ThrowStmt to throw a value that is not already
+// an error value
+type thrownValue struct {
+	value any
+	pos   Pos
+	file  string
+	trace []Frame
+}
+
+func (t thrownValue) Error() string {
+	return fmt.Sprintf("uncaught exception: %s (%s)", stringify(t.value), t.pos)
+}
+
+// uncaughtError returns the error for a panic that unwound out of a program.
+// An uncaught throw of a value becomes a RuntimeError. Go runtime errors are
+// bugs in the interpreter rather than the program, so they keep panicking.
+func uncaughtError(r any) error {
+	switch v := r.(type) {
+	case thrownValue:
+		err := runtimeError(v.pos, ErrorKindError, "uncaught exception: %s", stringify(v.value))
+		err.Trace = v.trace
+		err.File = v.file
+		return err
+	case runtime.Error:
+		panic(v)
+	case error:
+		return v
+	}
+	panic(r)
+}
+
+// caughtValue returns the Lox value for a recovered panic, if it is one that a
+// try statement can catch. Control flow such as return and break is not.
+func caughtValue(r any) (any, bool) {
+	switch v := r.(type) {
+	case thrownValue:
+		return v.value, true
+	case *RuntimeError:
+		return v, true
+	case *ModuleError:
+		return v.importError(), true
+	}
+	return nil, false
+}
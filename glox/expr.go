@@ -2,6 +2,8 @@ package glox
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -27,8 +29,47 @@ func ExprToString(e Expr) string {
 		return fmt.Sprintf("(id %s)", v.name.Lexeme)
 	case Assign:
 		return parenthesize("set "+v.name.Lexeme, v.val)
+	case Logical:
+		return parenthesize(v.operator.Lexeme, v.left, v.right)
+	case Conditional:
+		return parenthesize("?:", v.condition, v.thenBranch, v.elseBranch)
 	case Call:
 		return parenthesize("call "+ExprToString(v.callee), v.args...)
+	case Get:
+		return parenthesize("get "+v.name.Lexeme, v.object)
+	case Set:
+		return parenthesize("set "+v.name.Lexeme, v.object, v.val)
+	case This:
+		return "(this)"
+	case Super:
+		return fmt.Sprintf("(super %s)", v.method.Lexeme)
+	case Interpolation:
+		return parenthesize("interpolate", v.parts...)
+	case ListExpr:
+		return parenthesize("list", v.elements...)
+	case MapExpr:
+		entries := make([]Expr, 0, 2*len(v.keys))
+		for i := range v.keys {
+			entries = append(entries, v.keys[i], v.values[i])
+		}
+		return parenthesize("map", entries...)
+	case GetIndex:
+		return parenthesize("index", v.object, v.index)
+	case SetIndex:
+		return parenthesize("set-index", v.object, v.index, v.val)
+	case CompoundAssign:
+		return parenthesize(v.operator.Lexeme, v.target, v.val)
+	case Update:
+		if v.prefix {
+			return parenthesize("pre"+v.operator.Lexeme, v.target)
+		}
+		return parenthesize("post"+v.operator.Lexeme, v.target)
+	case FuncExpr:
+		params := make([]string, 0, len(v.params))
+		for _, param := range v.params {
+			params = append(params, param.Lexeme)
+		}
+		return "(fun (" + strings.Join(params, " ") + ")\n" + strings.Join(StmtsToStrings(v.body), "\n") + ")"
 	}
 	return fmt.Sprintf("unknown expr type: %v", e)
 }
@@ -42,8 +83,11 @@ func (e UnaryExpr) Evaluate(env *Environment) any {
 	right := e.right.Evaluate(env)
 	switch e.operator.Type {
 	case TokenTypeMinus:
-		// TODO: check for runtime errors (can only negate numbers)
-		return -right.(float64)
+		n, ok := right.(float64)
+		if !ok {
+			panic(tokenError(e.operator, ErrorKindType, "operand of '-' must be a number, got %s", typeName(right)))
+		}
+		return -n
 	case TokenTypeBang:
 		return !isTruthy(right)
 	}
@@ -71,15 +115,32 @@ func (e BinaryExpr) Pos() Pos {
 func (e BinaryExpr) Evaluate(env *Environment) any {
 	left := e.left.Evaluate(env)
 	right := e.right.Evaluate(env)
+	return evalBinary(e.operator, left, right)
+}
 
-	// TODO: check for runtime errors
-	switch e.operator.Type {
+// evalBinary applies a binary operator to its evaluated operands
+func evalBinary(operator Token, left, right any) any {
+	switch operator.Type {
 	case TokenTypeMinus:
-		return left.(float64) - right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l - r
 	case TokenTypeSlash:
-		return left.(float64) / right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l / r
 	case TokenTypeStar:
-		return left.(float64) * right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l * r
+	case TokenTypePercent:
+		l, r := checkNumberOperands(operator, left, right)
+		checkNonZeroDivisor(operator, r)
+		return floorMod(l, r)
+	case TokenTypeTildeSlash:
+		l, r := checkNumberOperands(operator, left, right)
+		checkNonZeroDivisor(operator, r)
+		return math.Floor(l / r)
+	case TokenTypeStarStar:
+		l, r := checkNumberOperands(operator, left, right)
+		return math.Pow(l, r)
 	case TokenTypePlus:
 		// Special case: we can add numbers or concatenate strings
 		switch l := left.(type) {
@@ -92,14 +153,19 @@ func (e BinaryExpr) Evaluate(env *Environment) any {
 				return l + r
 			}
 		}
+		panic(tokenError(operator, ErrorKindType, "operands of '%s' must be two numbers or two strings, got %s and %s", operator.Lexeme, typeName(left), typeName(right)))
 	case TokenTypeGreater:
-		return left.(float64) > right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l > r
 	case TokenTypeGreaterEqual:
-		return left.(float64) >= right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l >= r
 	case TokenTypeLess:
-		return left.(float64) < right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l < r
 	case TokenTypeLessEqual:
-		return left.(float64) <= right.(float64)
+		l, r := checkNumberOperands(operator, left, right)
+		return l <= r
 	case TokenTypeBangEqual:
 		return !isEqual(left, right)
 	case TokenTypeEqualEqual:
@@ -108,6 +174,33 @@ func (e BinaryExpr) Evaluate(env *Environment) any {
 	return nil
 }
 
+// checkNumberOperands asserts that both operands of a binary operator are
+// numbers and returns them
+func checkNumberOperands(operator Token, left, right any) (float64, float64) {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		panic(tokenError(operator, ErrorKindType, "operands of '%s' must be numbers, got %s and %s", operator.Lexeme, typeName(left), typeName(right)))
+	}
+	return l, r
+}
+
+func checkNonZeroDivisor(operator Token, r float64) {
+	if r == 0 {
+		panic(tokenError(operator, ErrorKindArithmetic, "division by zero in '%s'", operator.Lexeme))
+	}
+}
+
+// floorMod is the remainder of floored division, which takes the sign of the
+// divisor, so that a == (a ~/ b) * b + a % b
+func floorMod(l, r float64) float64 {
+	m := math.Mod(l, r)
+	if m != 0 && (m < 0) != (r < 0) {
+		m += r
+	}
+	return m
+}
+
 type Literal struct {
 	token Token
 	value interface{}
@@ -139,32 +232,82 @@ func (e Grouping) Pos() Pos {
 	}
 }
 
+// Interpolation is a string with embedded expressions, such as "Hi, ${name}!".
+// Its parts alternate between string literals and expressions, starting and
+// ending with a literal.
+type Interpolation struct {
+	parts []Expr
+}
+
+func (e Interpolation) Evaluate(env *Environment) any {
+	builder := &strings.Builder{}
+	for _, part := range e.parts {
+		builder.WriteString(stringify(part.Evaluate(env)))
+	}
+	return builder.String()
+}
+
+func (e Interpolation) Pos() Pos {
+	return Pos{
+		Line:  e.parts[0].Pos().Line,
+		Start: e.parts[0].Pos().Start,
+		End:   e.parts[len(e.parts)-1].Pos().End,
+	}
+}
+
 type Identifier struct {
 	name Token
+	ref  *varRef
 }
 
 func (e Identifier) Evaluate(env *Environment) any {
-	v, ok := env.Get(e.name.Lexeme)
+	v, ok := env.GetAt(e.ref.depth, e.name.Lexeme)
 	if !ok {
-		panic(fmt.Errorf("unknown identifier %s", e.name))
+		err := tokenError(e.name, ErrorKindName, "undefined variable '%s'", e.name.Lexeme)
+		err.Hint = undefinedHint(env, e.name.Lexeme)
+		panic(err)
 	}
 	return v
 }
 
+// undefinedHint suggests a variable visible from env, or a literal keyword,
+// that an undefined name may be a misspelling of
+func undefinedHint(env *Environment, name string) string {
+	return didYouMean(name, append(env.names(), "nil", "true", "false", "this"))
+}
+
 func (e Identifier) Pos() Pos {
 	return e.name.Pos
 }
 
+func (e Identifier) bind(env *Environment) reference {
+	return reference{
+		get: func() any {
+			return e.Evaluate(env)
+		},
+		set: func(v any) {
+			if err := env.SetAt(e.ref.depth, e.name.Lexeme, v); err != nil {
+				panic(atToken(err, e.name))
+			}
+		},
+	}
+}
+
 type Assign struct {
 	name Token
 	val  Expr
+	ref  *varRef
 }
 
 func (e Assign) Evaluate(env *Environment) any {
 	v := e.val.Evaluate(env)
-	err := env.Set(e.name.Lexeme, v)
+	err := env.SetAt(e.ref.depth, e.name.Lexeme, v)
 	if err != nil {
-		panic(err)
+		rerr := atToken(err, e.name)
+		if _, ok := env.GetAt(e.ref.depth, e.name.Lexeme); !ok {
+			rerr.Hint = undefinedHint(env, e.name.Lexeme)
+		}
+		panic(rerr)
 	}
 	return v
 }
@@ -177,6 +320,104 @@ func (e Assign) Pos() Pos {
 	}
 }
 
+// assignTarget is implemented by expressions that can be assigned to. bind
+// evaluates the target's subexpressions, such as the object of a property,
+// exactly once and returns a reference that can then be read and written.
+type assignTarget interface {
+	Expr
+	bind(env *Environment) reference
+}
+
+var (
+	_ assignTarget = Identifier{}
+	_ assignTarget = Get{}
+	_ assignTarget = GetIndex{}
+)
+
+// reference is a bound assignment target: a variable, a property of a specific
+// instance or an element of a specific collection
+type reference struct {
+	get func() any
+	set func(v any)
+}
+
+// compoundOperators maps each compound assignment operator to the binary
+// operator it applies
+var compoundOperators = map[TokenType]TokenType{
+	TokenTypePlusEqual:    TokenTypePlus,
+	TokenTypeMinusEqual:   TokenTypeMinus,
+	TokenTypeStarEqual:    TokenTypeStar,
+	TokenTypeSlashEqual:   TokenTypeSlash,
+	TokenTypePercentEqual: TokenTypePercent,
+}
+
+// CompoundAssign combines a target's value with another and assigns the result
+// back to it, as in x += 1
+type CompoundAssign struct {
+	target   assignTarget
+	operator Token
+	val      Expr
+}
+
+func (e CompoundAssign) Evaluate(env *Environment) any {
+	ref := e.target.bind(env)
+	current := ref.get()
+	operator := e.operator
+	operator.Type = compoundOperators[e.operator.Type]
+	v := evalBinary(operator, current, e.val.Evaluate(env))
+	ref.set(v)
+	return v
+}
+
+func (e CompoundAssign) Pos() Pos {
+	return Pos{
+		Line:  e.target.Pos().Line,
+		Start: e.target.Pos().Start,
+		End:   e.val.Pos().End,
+	}
+}
+
+// Update is an increment or decrement, such as ++x or x--. The prefix form
+// evaluates to the new value and the postfix form to the old one.
+type Update struct {
+	target   assignTarget
+	operator Token
+	prefix   bool
+}
+
+func (e Update) Evaluate(env *Environment) any {
+	ref := e.target.bind(env)
+	current := ref.get()
+	old, ok := current.(float64)
+	if !ok {
+		panic(tokenError(e.operator, ErrorKindType, "operand of '%s' must be a number, got %s", e.operator.Lexeme, typeName(current)))
+	}
+	updated := old + 1
+	if e.operator.Type == TokenTypeMinusMinus {
+		updated = old - 1
+	}
+	ref.set(updated)
+	if e.prefix {
+		return updated
+	}
+	return old
+}
+
+func (e Update) Pos() Pos {
+	if e.prefix {
+		return Pos{
+			Line:  e.operator.Pos.Line,
+			Start: e.operator.Pos.Start,
+			End:   e.target.Pos().End,
+		}
+	}
+	return Pos{
+		Line:  e.target.Pos().Line,
+		Start: e.target.Pos().Start,
+		End:   e.operator.Pos.End,
+	}
+}
+
 type Logical struct {
 	left     Expr
 	operator Token
@@ -195,6 +436,10 @@ func (e Logical) Evaluate(env *Environment) any {
 		if !isTruthy(left) {
 			return left
 		}
+	case TokenTypeQuestionQuestion:
+		if left != nil {
+			return left
+		}
 	}
 	return e.right.Evaluate(env)
 }
@@ -207,25 +452,87 @@ func (e Logical) Pos() Pos {
 	}
 }
 
+// Conditional is the ternary cond ? thenBranch : elseBranch. Only the branch
+// selected by the condition is evaluated.
+type Conditional struct {
+	condition  Expr
+	question   Token
+	thenBranch Expr
+	elseBranch Expr
+}
+
+func (e Conditional) Evaluate(env *Environment) any {
+	if isTruthy(e.condition.Evaluate(env)) {
+		return e.thenBranch.Evaluate(env)
+	}
+	return e.elseBranch.Evaluate(env)
+}
+
+func (e Conditional) Pos() Pos {
+	return Pos{
+		Line:  e.condition.Pos().Line,
+		Start: e.condition.Pos().Start,
+		End:   e.elseBranch.Pos().End,
+	}
+}
+
 type Call struct {
 	callee Expr
 	paren  Token
 	args   []Expr
 }
 
+// maxCallDepth is the number of nested Lox calls allowed before a call raises a
+// StackOverflowError, well before the interpreter would exhaust the Go stack
+const maxCallDepth = 10000
+
 func (e Call) Evaluate(env *Environment) any {
 	callee := e.callee.Evaluate(env)
 	args := []any{}
 	for _, arg := range e.args {
 		args = append(args, arg.Evaluate(env))
 	}
-	if function, ok := callee.(Caller); ok {
-		if function.Arity() != len(args) {
-			panic(fmt.Errorf("expected %d args but got %d in call to %s", function.Arity(), len(args), e.callee))
+	function, ok := callee.(Caller)
+	if !ok {
+		panic(runtimeError(e.callee.Pos(), ErrorKindType, "can only call functions and classes, got %s", typeName(callee)))
+	}
+	native, isNative := function.(*NativeFunc)
+	if minArity := function.Arity(); isNative && native.optional > 0 {
+		minArity -= native.optional
+		if len(args) < minArity || len(args) > function.Arity() {
+			panic(runtimeError(e.Pos(), ErrorKindType, "expected %d to %d args but got %d in call to %s", minArity, function.Arity(), len(args), function))
+		}
+	} else if function.Arity() != len(args) {
+		panic(runtimeError(e.Pos(), ErrorKindType, "expected %d args but got %d in call to %s", function.Arity(), len(args), function))
+	}
+	if isNative {
+		// Builtins report errors without a position; place them at the call
+		v, err := native.fn(args)
+		if err != nil {
+			panic(atPos(err, e.Pos()))
 		}
-		return function.Call(env, args)
+		return v
 	}
-	panic(fmt.Errorf("uncallable expression: %v", callee))
+	return callFunction(env, function, args, e.Pos(), e.paren.Pos)
+}
+
+// callFunction calls a Lox function or class from env, counting the call
+// against maxCallDepth and adding a frame at framePos to the trace of errors
+// that unwind through it. pos is the position of the whole call.
+func callFunction(env *Environment, function Caller, args []any, pos, framePos Pos) any {
+	module := moduleOf(env)
+	if module.loader.depth >= maxCallDepth {
+		panic(runtimeError(pos, ErrorKindStackOverflow, "maximum call depth of %d exceeded in call to %s", maxCallDepth, function))
+	}
+	module.loader.depth++
+	defer func() {
+		module.loader.depth--
+		if r := recover(); r != nil {
+			frame := Frame{Function: frameName(function), File: module.path, Pos: framePos}
+			panic(addFrame(r, function, frame))
+		}
+	}()
+	return function.Call(env, args)
 }
 
 func (e Call) Pos() Pos {
@@ -236,6 +543,143 @@ func (e Call) Pos() Pos {
 	}
 }
 
+// propertyGetter is implemented by runtime values whose properties can be read
+// with a Get expression
+type propertyGetter interface {
+	Get(name Token) any
+}
+
+var (
+	_ propertyGetter = &LoxInstance{}
+	_ propertyGetter = &RuntimeError{}
+	_ propertyGetter = &Module{}
+)
+
+type Get struct {
+	object Expr
+	name   Token
+}
+
+func (e Get) Evaluate(env *Environment) any {
+	object := e.object.Evaluate(env)
+	if getter, ok := object.(propertyGetter); ok {
+		return getter.Get(e.name)
+	}
+	panic(tokenError(e.name, ErrorKindType, "cannot read property '%s' of %s: only instances have properties", e.name.Lexeme, typeName(object)))
+}
+
+func (e Get) Pos() Pos {
+	return Pos{
+		Line:  e.object.Pos().Line,
+		Start: e.object.Pos().Start,
+		End:   e.name.Pos.End,
+	}
+}
+
+func (e Get) bind(env *Environment) reference {
+	object := e.object.Evaluate(env)
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(tokenError(e.name, ErrorKindType, "cannot assign to property '%s' of %s: only instances have fields", e.name.Lexeme, typeName(object)))
+	}
+	return reference{
+		get: func() any {
+			return instance.Get(e.name)
+		},
+		set: func(v any) {
+			instance.Set(e.name, v)
+		},
+	}
+}
+
+type Set struct {
+	object Expr
+	name   Token
+	val    Expr
+}
+
+func (e Set) Evaluate(env *Environment) any {
+	object := e.object.Evaluate(env)
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(tokenError(e.name, ErrorKindType, "cannot set property '%s' on %s: only instances have fields", e.name.Lexeme, typeName(object)))
+	}
+	v := e.val.Evaluate(env)
+	instance.Set(e.name, v)
+	return v
+}
+
+func (e Set) Pos() Pos {
+	return Pos{
+		Line:  e.object.Pos().Line,
+		Start: e.object.Pos().Start,
+		End:   e.val.Pos().End,
+	}
+}
+
+type This struct {
+	keyword Token
+	ref     *varRef
+}
+
+func (e This) Evaluate(env *Environment) any {
+	v, ok := env.GetAt(e.ref.depth, "this")
+	if !ok {
+		panic(tokenError(e.keyword, ErrorKindName, "'this' is not bound"))
+	}
+	return v
+}
+
+func (e This) Pos() Pos {
+	return e.keyword.Pos
+}
+
+type Super struct {
+	keyword Token
+	method  Token
+	ref     *varRef
+}
+
+func (e Super) Evaluate(env *Environment) any {
+	superclass, _ := env.GetAt(e.ref.depth, "super")
+	// The environment binding this is always just inside the one binding super
+	instance, _ := env.GetAt(e.ref.depth-1, "this")
+	method, ok := superclass.(*LoxClass).findMethod(e.method.Lexeme)
+	if !ok {
+		panic(tokenError(e.method, ErrorKindName, "undefined property '%s'", e.method.Lexeme))
+	}
+	return method.bind(instance.(*LoxInstance))
+}
+
+func (e Super) Pos() Pos {
+	return Pos{
+		Line:  e.keyword.Pos.Line,
+		Start: e.keyword.Pos.Start,
+		End:   e.method.Pos.End,
+	}
+}
+
+// FuncExpr is an anonymous function, such as fun (a, b) { return a + b; }
+type FuncExpr struct {
+	keyword Token
+	params  []Token
+	body    []Stmt
+}
+
+// decl returns the function as a declaration named by its fun keyword, which
+// is how DefinedFunc recognizes anonymous functions.
+func (e FuncExpr) decl() FuncDecl {
+	return FuncDecl{name: e.keyword, params: e.params, body: e.body}
+}
+
+func (e FuncExpr) Evaluate(env *Environment) any {
+	return &DefinedFunc{decl: e.decl(), closure: env}
+}
+
+func (e FuncExpr) Pos() Pos {
+	return e.keyword.Pos
+}
+
 func isTruthy(v any) bool {
 	if v == nil {
 		return false
@@ -256,6 +700,70 @@ func isEqual(a, b any) bool {
 	return a == b
 }
 
+// stringify formats a runtime value the way print displays it
+func stringify(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case *RuntimeError:
+		// fmt would use Error, which includes the position for Go callers
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// repr formats a value as it appears inside a printed collection, where
+// strings are quoted
+func repr(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return stringify(v)
+}
+
+// reprIn is repr for an element of a collection being printed, which passes on
+// the collections already being printed so that cycles end
+func reprIn(v any, printing map[any]bool) string {
+	switch v := v.(type) {
+	case *LoxList:
+		return v.format(printing)
+	case *LoxMap:
+		return v.format(printing)
+	}
+	return repr(v)
+}
+
+// typeName describes the Lox type of a runtime value, for error messages
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *LoxClass:
+		return "class"
+	case *LoxInstance:
+		return "instance"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *LoxRange:
+		return "range"
+	case *RuntimeError:
+		return "error"
+	case *Module:
+		return "module"
+	case Caller:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
 func parenthesize(name string, exprs ...Expr) string {
 	builder := &strings.Builder{}
 	builder.WriteByte('(')
@@ -13,25 +13,59 @@ type Caller interface {
 
 type DefinedFunc struct {
 	decl FuncDecl
+	// closure is the environment the function was declared in. Calls extend it
+	// rather than the caller's environment, so functions are lexically scoped.
+	closure *Environment
+	// isInitializer is set for a class's init method, which always returns the
+	// instance it was bound to.
+	isInitializer bool
 }
 
-var _ Caller = DefinedFunc{}
+var _ Caller = &DefinedFunc{}
 
-func (f DefinedFunc) Arity() int { return len(f.decl.params) }
+func (f *DefinedFunc) Arity() int { return len(f.decl.params) }
 
-func (f DefinedFunc) Call(env *Environment, args []any) any {
-	funcEnv := NewEnvironment(env)
+func (f *DefinedFunc) Call(env *Environment, args []any) (result any) {
+	funcEnv := NewEnvironment(f.closure)
 	for i := range f.decl.params {
 		funcEnv.Declare(f.decl.params[i].Lexeme, args[i])
 	}
 
+	// Return statements unwind by panicking; anything else keeps propagating
+	defer func() {
+		if r := recover(); r != nil {
+			ret, ok := r.(returnValue)
+			if !ok {
+				panic(r)
+			}
+			result = ret.value
+		}
+		if f.isInitializer {
+			result, _ = f.closure.Get("this")
+		}
+	}()
+
 	for _, stmt := range f.decl.body {
 		stmt.Execute(funcEnv)
 	}
 	return nil
 }
 
-func (f DefinedFunc) String() string {
+// bind returns a copy of the method whose closure defines this as instance
+func (f *DefinedFunc) bind(instance *LoxInstance) *DefinedFunc {
+	env := NewEnvironment(f.closure)
+	env.Declare("this", instance)
+	return &DefinedFunc{
+		decl:          f.decl,
+		closure:       env,
+		isInitializer: f.isInitializer,
+	}
+}
+
+func (f *DefinedFunc) String() string {
+	if f.decl.name.Type == TokenTypeFun {
+		return fmt.Sprintf("<fn anonymous@line %d>", f.decl.name.Pos.Line+1)
+	}
 	return fmt.Sprintf("<fn %s>", f.decl.name.Lexeme)
 }
 
@@ -0,0 +1,106 @@
+package glox
+
+import (
+	"fmt"
+)
+
+// LoxRange is the sequence of numbers from start towards end, exclusive, in
+// increments of step, as created by the range builtin
+type LoxRange struct {
+	start, end, step float64
+}
+
+func (r *LoxRange) String() string {
+	return fmt.Sprintf("range(%s, %s, %s)", stringify(r.start), stringify(r.end), stringify(r.step))
+}
+
+// iterator yields successive values of an iterable, returning false once the
+// values are exhausted
+type iterator func() (any, bool)
+
+// iterate returns an iterator over v, reporting values that cannot be iterated
+// at pos.
+//
+// Strings yield their characters, ranges their numbers, lists their elements
+// and maps their keys, in insertion order. Instances can implement iteration
+// with an iterator() method returning an object with hasNext() and next()
+// methods. An instance with hasNext() and next() methods is its own iterator.
+func iterate(env *Environment, v any, pos Pos) iterator {
+	switch v := v.(type) {
+	case string:
+		chars := []rune(v)
+		i := 0
+		return func() (any, bool) {
+			if i >= len(chars) {
+				return nil, false
+			}
+			i++
+			return string(chars[i-1]), true
+		}
+	case *LoxRange:
+		// Each value is computed from a count of steps, rather than by adding
+		// step repeatedly, so that fractional steps don't accumulate error
+		i := 0.0
+		return func() (any, bool) {
+			n := v.start + i*v.step
+			if v.step > 0 && n >= v.end || v.step < 0 && n <= v.end {
+				return nil, false
+			}
+			i++
+			return n, true
+		}
+	case *LoxList:
+		// Elements appended during the loop are visited too
+		i := 0
+		return func() (any, bool) {
+			if i >= len(v.elements) {
+				return nil, false
+			}
+			i++
+			return v.elements[i-1], true
+		}
+	case *LoxMap:
+		// Iterate a snapshot, so the loop can delete keys as it goes
+		keys := append([]any{}, v.keys...)
+		i := 0
+		return func() (any, bool) {
+			if i >= len(keys) {
+				return nil, false
+			}
+			i++
+			return keys[i-1], true
+		}
+	case *LoxInstance:
+		it := v
+		if _, ok := v.class.findMethod("iterator"); ok {
+			result := callMethod(env, v, "iterator", pos)
+			instance, ok := result.(*LoxInstance)
+			if !ok {
+				panic(runtimeError(pos, ErrorKindType, "iterator() must return an instance, got %s", typeName(result)))
+			}
+			it = instance
+		}
+		for _, name := range []string{"hasNext", "next"} {
+			if _, ok := it.class.findMethod(name); !ok {
+				panic(runtimeError(pos, ErrorKindType, "%s instance is not an iterator: it has no %s() method", it.class.name, name))
+			}
+		}
+		return func() (any, bool) {
+			if !isTruthy(callMethod(env, it, "hasNext", pos)) {
+				return nil, false
+			}
+			return callMethod(env, it, "next", pos), true
+		}
+	}
+	panic(runtimeError(pos, ErrorKindType, "cannot iterate over %s", typeName(v)))
+}
+
+// callMethod calls a method of the iteration protocol, which takes no args.
+// The call is made at pos, the loop's iterable, for stack traces.
+func callMethod(env *Environment, instance *LoxInstance, name string, pos Pos) any {
+	method, _ := instance.class.findMethod(name)
+	if method.Arity() != 0 {
+		panic(runtimeError(pos, ErrorKindType, "%s.%s() must take no args, but takes %d", instance.class.name, name, method.Arity()))
+	}
+	return callFunction(env, method.bind(instance), nil, pos, pos)
+}
@@ -0,0 +1,161 @@
+package glox
+
+import (
+	"math"
+	"strings"
+)
+
+type LoxList struct {
+	elements []any
+}
+
+func (l *LoxList) String() string {
+	return l.format(map[any]bool{})
+}
+
+// format formats the list, printing [...] for a list that contains itself.
+// printing holds the collections being formatted further out.
+func (l *LoxList) format(printing map[any]bool) string {
+	if printing[l] {
+		return "[...]"
+	}
+	printing[l] = true
+	defer delete(printing, l)
+	strs := make([]string, 0, len(l.elements))
+	for _, element := range l.elements {
+		strs = append(strs, reprIn(element, printing))
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// listIndex checks that v is a valid index into a list of the given length
+// and converts it to an int.
+func listIndex(v any, length int) (int, error) {
+	n, ok := v.(float64)
+	if !ok || n != math.Trunc(n) {
+		return 0, runtimeError(Pos{}, ErrorKindType, "list index must be an integer, got %s", stringify(v))
+	}
+	if n < 0 {
+		return 0, runtimeError(Pos{}, ErrorKindIndex, "negative list index %s", stringify(v))
+	}
+	if n >= float64(length) {
+		return 0, runtimeError(Pos{}, ErrorKindIndex, "list index %s out of range for length %d", stringify(v), length)
+	}
+	return int(n), nil
+}
+
+// ListExpr is a list literal, such as [1, 2, 3]
+type ListExpr struct {
+	left     Token
+	elements []Expr
+	right    Token
+}
+
+func (e ListExpr) Evaluate(env *Environment) any {
+	elements := make([]any, 0, len(e.elements))
+	for _, element := range e.elements {
+		elements = append(elements, element.Evaluate(env))
+	}
+	return &LoxList{elements: elements}
+}
+
+func (e ListExpr) Pos() Pos {
+	return Pos{
+		Line:  e.left.Pos.Line,
+		Start: e.left.Pos.Start,
+		End:   e.right.Pos.End,
+	}
+}
+
+// GetIndex reads an element with a subscript, such as xs[i]
+type GetIndex struct {
+	object Expr
+	index  Expr
+	right  Token
+}
+
+func (e GetIndex) Evaluate(env *Environment) any {
+	return e.bind(env).get()
+}
+
+func (e GetIndex) bind(env *Environment) reference {
+	object := e.object.Evaluate(env)
+	index := e.index.Evaluate(env)
+	return indexReference(object, index, e)
+}
+
+// indexReference returns a reference to the element of a list or map at
+// index. e is the subscript expression, for error positions.
+func indexReference(object, index any, e GetIndex) reference {
+	switch collection := object.(type) {
+	case *LoxList:
+		// The index is checked again on each access, since evaluating the
+		// value assigned, as in xs[0] = pop(xs), can shrink the list
+		at := func() int {
+			i, err := listIndex(index, len(collection.elements))
+			if err != nil {
+				panic(atPos(err, e.index.Pos()))
+			}
+			return i
+		}
+		at()
+		return reference{
+			get: func() any {
+				return collection.elements[at()]
+			},
+			set: func(v any) {
+				collection.elements[at()] = v
+			},
+		}
+	case *LoxMap:
+		if err := checkMapKey(index); err != nil {
+			panic(atPos(err, e.index.Pos()))
+		}
+		return reference{
+			get: func() any {
+				v, err := collection.Get(index)
+				if err != nil {
+					panic(atPos(err, e.index.Pos()))
+				}
+				return v
+			},
+			set: func(v any) {
+				collection.Set(index, v)
+			},
+		}
+	}
+	panic(runtimeError(e.Pos(), ErrorKindType, "cannot index %s: only lists and maps can be indexed", typeName(object)))
+}
+
+func (e GetIndex) Pos() Pos {
+	return Pos{
+		Line:  e.object.Pos().Line,
+		Start: e.object.Pos().Start,
+		End:   e.right.Pos.End,
+	}
+}
+
+// SetIndex assigns to an element with a subscript, such as xs[i] = v
+type SetIndex struct {
+	object Expr
+	index  Expr
+	right  Token
+	val    Expr
+}
+
+func (e SetIndex) Evaluate(env *Environment) any {
+	object := e.object.Evaluate(env)
+	index := e.index.Evaluate(env)
+	ref := indexReference(object, index, GetIndex{object: e.object, index: e.index, right: e.right})
+	v := e.val.Evaluate(env)
+	ref.set(v)
+	return v
+}
+
+func (e SetIndex) Pos() Pos {
+	return Pos{
+		Line:  e.object.Pos().Line,
+		Start: e.object.Pos().Start,
+		End:   e.val.Pos().End,
+	}
+}
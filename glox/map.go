@@ -0,0 +1,115 @@
+package glox
+
+import (
+	"math"
+	"strings"
+)
+
+// LoxMap is a map from strings, numbers, booleans and nil to any value. Keys
+// are compared like isEqual and iterate in insertion order.
+type LoxMap struct {
+	keys    []any
+	entries map[any]any
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{entries: map[any]any{}}
+}
+
+// checkMapKey returns an error if v cannot be used as a map key
+func checkMapKey(v any) error {
+	switch v := v.(type) {
+	case float64:
+		// NaN is not equal to itself, so an entry under it could never be
+		// found again
+		if math.IsNaN(v) {
+			return runtimeError(Pos{}, ErrorKindType, "NaN cannot be used as a map key")
+		}
+		return nil
+	case nil, bool, string:
+		return nil
+	}
+	return runtimeError(Pos{}, ErrorKindType, "%s cannot be used as a map key", typeName(v))
+}
+
+func (m *LoxMap) Get(key any) (any, error) {
+	if err := checkMapKey(key); err != nil {
+		return nil, err
+	}
+	v, ok := m.entries[key]
+	if !ok {
+		return nil, runtimeError(Pos{}, ErrorKindKey, "key %s not found in map", repr(key))
+	}
+	return v, nil
+}
+
+// Set sets the value for a key. The key must already have been checked with
+// checkMapKey.
+func (m *LoxMap) Set(key, v any) {
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = v
+}
+
+// Delete removes a key, reporting whether it was present
+func (m *LoxMap) Delete(key any) bool {
+	if _, ok := m.entries[key]; !ok {
+		return false
+	}
+	delete(m.entries, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (m *LoxMap) String() string {
+	return m.format(map[any]bool{})
+}
+
+// format formats the map like LoxList.format, printing {...} for a map that
+// contains itself
+func (m *LoxMap) format(printing map[any]bool) string {
+	if printing[m] {
+		return "{...}"
+	}
+	printing[m] = true
+	defer delete(printing, m)
+	strs := make([]string, 0, len(m.keys))
+	for _, key := range m.keys {
+		strs = append(strs, repr(key)+": "+reprIn(m.entries[key], printing))
+	}
+	return "{" + strings.Join(strs, ", ") + "}"
+}
+
+// MapExpr is a map literal, such as {"a": 1, "b": 2}
+type MapExpr struct {
+	left   Token
+	keys   []Expr
+	values []Expr
+	right  Token
+}
+
+func (e MapExpr) Evaluate(env *Environment) any {
+	m := NewLoxMap()
+	for i := range e.keys {
+		key := e.keys[i].Evaluate(env)
+		if err := checkMapKey(key); err != nil {
+			panic(atPos(err, e.keys[i].Pos()))
+		}
+		m.Set(key, e.values[i].Evaluate(env))
+	}
+	return m
+}
+
+func (e MapExpr) Pos() Pos {
+	return Pos{
+		Line:  e.left.Pos.Line,
+		Start: e.left.Pos.Start,
+		End:   e.right.Pos.End,
+	}
+}
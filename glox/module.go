@@ -0,0 +1,139 @@
+package glox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Module is a loaded Lox file. Importing a file binds its Module as a namespace
+// value whose properties are the file's top-level declarations.
+type Module struct {
+	name string
+	// path is the file's path as it was resolved, used in messages and to
+	// resolve the file's own imports
+	path   string
+	env    *Environment
+	loader *moduleLoader
+}
+
+// NewModuleEnvironment returns a global environment for running the file at
+// path, whose imports are resolved relative to it
+func NewModuleEnvironment(path string) *Environment {
+	loader := newModuleLoader()
+	module := loader.newModule(path, NewEnvironment(nil))
+	// The main file stays loading for the whole run, so importing it back is
+	// reported as a cycle
+	loader.loading = append(loader.loading, module)
+	return module.env
+}
+
+// moduleOf returns the module whose global environment encloses env. Programs
+// run in an environment from NewEnvironment get a module without a path, and
+// resolve imports relative to the working directory.
+func moduleOf(env *Environment) *Module {
+	global := env.ancestor(-1)
+	if global.module == nil {
+		newModuleLoader().newModule("", global)
+	}
+	return global.module
+}
+
+// resolve returns the path of an import relative to this module's file
+func (m *Module) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(m.path), path)
+}
+
+// Get returns one of the module's top-level declarations
+func (m *Module) Get(name Token) any {
+	v, ok := m.env.vars[name.Lexeme]
+	if !ok {
+		panic(tokenError(name, ErrorKindName, "module %s has no declaration '%s'", m.name, name.Lexeme))
+	}
+	return v
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("<module %s>", m.name)
+}
+
+// moduleLoader loads and caches the modules imported while running a program
+type moduleLoader struct {
+	// modules holds every module loaded so far, by absolute path
+	modules map[string]*Module
+	// loading is the chain of modules currently being executed, outermost
+	// first, used to detect import cycles
+	loading []*Module
+	// depth is the number of Lox calls in progress across all the modules,
+	// limited to maxCallDepth
+	depth int
+}
+
+func newModuleLoader() *moduleLoader {
+	return &moduleLoader{modules: map[string]*Module{}}
+}
+
+func (l *moduleLoader) newModule(path string, env *Environment) *Module {
+	base := filepath.Base(path)
+	module := &Module{
+		name:   strings.TrimSuffix(base, filepath.Ext(base)),
+		path:   path,
+		env:    env,
+		loader: l,
+	}
+	env.module = module
+	return module
+}
+
+// load returns the module for the file at path, executing the file the first
+// time it is imported
+func (l *moduleLoader) load(path string) (*Module, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, runtimeError(Pos{}, ErrorKindImport, "cannot resolve module %s: %s", path, err)
+	}
+	for i, loading := range l.loading {
+		if loadingAbs, _ := filepath.Abs(loading.path); loading.path != "" && loadingAbs == abs {
+			cycle := []string{}
+			for _, m := range l.loading[i:] {
+				cycle = append(cycle, m.path)
+			}
+			return nil, runtimeError(Pos{}, ErrorKindImport, "import cycle: %s -> %s", strings.Join(cycle, " -> "), path)
+		}
+	}
+	if module, ok := l.modules[abs]; ok {
+		return module, nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, runtimeError(Pos{}, ErrorKindImport, "cannot read module %s: %s", path, err)
+	}
+	module := l.newModule(path, NewEnvironment(nil))
+	l.loading = append(l.loading, module)
+	err = module.execute(source)
+	l.loading = l.loading[:len(l.loading)-1]
+	if err != nil {
+		if _, ok := err.(*ModuleError); ok {
+			// A module imported by this one failed, and its importers are
+			// added to the error as it unwinds
+			return nil, err
+		}
+		return nil, &ModuleError{File: path, Err: err}
+	}
+	l.modules[abs] = module
+	return module, nil
+}
+
+// execute scans, parses and runs the module's source in its environment
+func (m *Module) execute(source []byte) error {
+	tokens, err := NewScanner(source).ScanTokens()
+	if err != nil {
+		return err
+	}
+	return NewParser(tokens).Execute(m.env)
+}
@@ -0,0 +1,242 @@
+package glox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModules writes each source to a file in a temporary directory, and
+// returns the directory
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runModule runs the file at path as the main module and returns its
+// environment, along with the error that stopped it, if any
+func runModule(t *testing.T, path string) (*Environment, error) {
+	t.Helper()
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewModuleEnvironment(path)
+	return env, NewParser(tokens).Execute(env)
+}
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox": `
+import "lib/shapes.lox";
+import "lib/shapes.lox" as again;
+import "counter.lox" as c;
+var area = shapes.Square(3).area();
+var name = shapes.name;
+var same = shapes == again;
+c.bump();
+import "other.lox";
+var total = other.total;
+`,
+		"lib/shapes.lox": `
+import "../counter.lox";
+counter.bump();
+var name = "shapes";
+class Square {
+  init(side) { this.side = side; }
+  area() { return this.side * this.side; }
+}
+`,
+		"counter.lox": `
+var count = 0;
+fun bump() { count = count + 1; }
+`,
+		"other.lox": `
+import "counter.lox";
+counter.bump();
+var total = counter.count;
+`,
+	})
+	env, err := runModule(t, filepath.Join(dir, "main.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectGlobal(t, env, "area", 9.0)
+	expectGlobal(t, env, "name", "shapes")
+	expectGlobal(t, env, "same", true)
+	// counter.lox runs once, and every importer shares its globals
+	expectGlobal(t, env, "total", 3.0)
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"main.lox": `import "a.lox";`,
+				"a.lox":    `import "b.lox";`,
+				"b.lox":    `import "a.lox";`,
+			},
+			expected: []string{"ImportError", "import cycle:", "a.lox -> ", "b.lox -> ", "imported by"},
+		},
+		{
+			name: "cycle to main",
+			files: map[string]string{
+				"main.lox": `import "a.lox";`,
+				"a.lox":    `import "main.lox";`,
+			},
+			expected: []string{"import cycle:", "main.lox -> "},
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"main.lox": `import "missing.lox";`,
+			},
+			expected: []string{"cannot read module", "missing.lox", "line 1"},
+		},
+		{
+			name: "error in module",
+			files: map[string]string{
+				"main.lox": "\nimport \"a.lox\";",
+				"a.lox":    `var x = 1 + nil;`,
+			},
+			expected: []string{"error in module", "a.lox", "line 2"},
+		},
+		{
+			name: "unknown declaration",
+			files: map[string]string{
+				"main.lox": `import "a.lox"; print a.y;`,
+				"a.lox":    `var x = 1;`,
+			},
+			expected: []string{"NameError", "module a has no declaration 'y'"},
+		},
+		{
+			name: "builtins are not exported",
+			files: map[string]string{
+				"main.lox": `import "a.lox"; print a.len;`,
+				"a.lox":    `var x = 1;`,
+			},
+			expected: []string{"module a has no declaration 'len'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeModules(t, tt.files)
+			_, err := runModule(t, filepath.Join(dir, "main.lox"))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error to contain %q, got %q", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCatchImportErrors(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox": `
+var kinds = [];
+var messages = [];
+var lines = [];
+for (path in ["missing.lox", "syntax.lox", "runtime.lox"]) {
+  try {
+    if (path == "missing.lox") { import "missing.lox"; }
+    if (path == "syntax.lox") { import "syntax.lox"; }
+    if (path == "runtime.lox") { import "runtime.lox"; }
+  } catch (e) {
+    append(kinds, e.kind);
+    append(messages, e.message);
+    append(lines, e.line);
+  }
+}
+`,
+		"syntax.lox":  "print (1;",
+		"runtime.lox": "var x = [][0];",
+	})
+	env, err := runModule(t, filepath.Join(dir, "main.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds, _ := env.Get("kinds")
+	if s := stringify(kinds); s != `["ImportError", "ImportError", "ImportError"]` {
+		t.Errorf("Expected three ImportErrors, got %s", s)
+	}
+	lines, _ := env.Get("lines")
+	if s := stringify(lines); s != "[7, 8, 9]" {
+		t.Errorf("Expected errors at the import statements, got %s", s)
+	}
+	messages, _ := env.Get("messages")
+	for _, expected := range []string{"cannot read module", "syntax.lox: expected ')'", "runtime.lox: IndexError: list index 0"} {
+		if !strings.Contains(stringify(messages), expected) {
+			t.Errorf("Expected a message containing %q, got %s", expected, messages)
+		}
+	}
+}
+
+func TestImportNeedsName(t *testing.T) {
+	tokens, err := NewScanner([]byte(`import "my-lib.lox";`)).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := NewParser(tokens).Program()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "use 'as'") {
+		t.Errorf("Expected error asking for 'as', got %v", errs)
+	}
+}
+
+func TestModuleErrorDiagnostics(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox":  "\nimport \"lib/a.lox\";",
+		"lib/a.lox": "import \"b.lox\";",
+		"lib/b.lox": "var x = 1;\nprint (x;",
+	})
+	diags, sources := runDiagnostics(t, dir)
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %+v", diags)
+	}
+	diag := diags[0]
+	if diag.Code != CodeSyntax || diag.File != filepath.Join(dir, "lib/b.lox") || diag.Pos.Line != 1 {
+		t.Errorf("Expected the syntax error in b.lox, got %+v", diag)
+	}
+	// The import chain is related to the error, innermost first
+	expected := []Related{
+		{Message: "imported here", File: filepath.Join(dir, "lib/a.lox")},
+		{Message: "imported here", File: filepath.Join(dir, "main.lox"), Pos: Pos{Line: 1}},
+	}
+	if len(diag.Related) != len(expected) {
+		t.Fatalf("Expected %d related locations, got %+v", len(expected), diag.Related)
+	}
+	for i, related := range diag.Related {
+		if related.Message != expected[i].Message || related.File != expected[i].File || related.Pos.Line != expected[i].Pos.Line {
+			t.Errorf("Expected related location %+v, got %+v", expected[i], related)
+		}
+	}
+
+	builder := &strings.Builder{}
+	diag.Render(builder, sources(diag.File), false)
+	if !strings.Contains(builder.String(), "2 | print (x;") {
+		t.Errorf("Expected the module's source line, got\n%s", builder)
+	}
+}
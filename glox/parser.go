@@ -2,19 +2,36 @@ package glox
 
 import (
 	"fmt"
-	"strconv"
+	"path/filepath"
+	"strings"
 )
 
+// Parser builds statements from a token stream. Comment tokens are left in the
+// stream for tools such as formatters, but are skipped wherever they appear.
 type Parser struct {
 	tokens  []Token
 	current int
+	// previousIndex is the index of the last token consumed, which is not
+	// necessarily current-1 when comments were skipped.
+	previousIndex int
+	// errs collects the syntax errors reported so far
+	errs []ParseError
 }
 
 func NewParser(tokens []Token) *Parser {
-	return &Parser{
+	p := &Parser{
 		tokens:  tokens,
 		current: 0,
 	}
+	p.skipComments()
+	return p
+}
+
+// skipComments moves current past any comment tokens
+func (p *Parser) skipComments() {
+	for p.current < len(p.tokens)-1 && p.tokens[p.current].Type == TokenTypeComment {
+		p.current++
+	}
 }
 
 func (p *Parser) isAtEnd() bool {
@@ -30,13 +47,15 @@ func (p *Parser) peek() Token {
 func (p *Parser) advance() Token {
 	t := p.tokens[p.current]
 	if !p.isAtEnd() {
+		p.previousIndex = p.current
 		p.current++
+		p.skipComments()
 	}
 	return t
 }
 
 func (p *Parser) previous() Token {
-	return p.tokens[p.current-1]
+	return p.tokens[p.previousIndex]
 }
 
 // check returns true if the next token is of the given type
@@ -47,6 +66,18 @@ func (p *Parser) check(t TokenType) bool {
 	return p.peek().Type == t
 }
 
+// checkNext returns true if the token after the next one is of the given type
+func (p *Parser) checkNext(t TokenType) bool {
+	next := p.current + 1
+	for next < len(p.tokens) && p.tokens[next].Type == TokenTypeComment {
+		next++
+	}
+	if p.isAtEnd() || next >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[next].Type == t
+}
+
 // match consumes a token as long as the type is one of the provided types
 func (p *Parser) match(types ...TokenType) bool {
 	for _, t := range types {
@@ -58,90 +89,222 @@ func (p *Parser) match(types ...TokenType) bool {
 	return false
 }
 
-// consume consumes a token, as long as it is of the given type
-func (p *Parser) consume(t TokenType) error {
-	next := p.peek()
-	if next.Type == t {
+// consume consumes and returns the next token if it is of the given type.
+// Otherwise it panics with a ParseError, using message to say what was
+// expected.
+func (p *Parser) consume(t TokenType, message string) Token {
+	if p.check(t) {
+		return p.advance()
+	}
+	err := p.errorAt(p.peek(), "%s, got %s", message, describe(p.peek()))
+	err.Expected = t
+	panic(err)
+}
+
+// describe names a token found in place of an expected one
+func describe(t Token) string {
+	if t.Type == TokenTypeEOF {
+		return "end of file"
+	}
+	return "'" + t.Lexeme + "'"
+}
+
+// errorAt creates a ParseError at the token found where something else was
+// expected. Parsing methods panic with it to unwind to the enclosing
+// declaration, which reports it and synchronizes.
+func (p *Parser) errorAt(found Token, format string, args ...any) *ParseError {
+	pos := found.Pos
+	if found.Type == TokenTypeEOF && len(p.tokens) > 1 {
+		// Point just past the last token, rather than at the start of the
+		// line after it
+		last := p.previous().Pos
+		pos = Pos{Line: last.Line, Start: last.End, End: last.End + 1}
+	}
+	return &ParseError{
+		Message: fmt.Sprintf(format, args...),
+		Pos:     pos,
+		Found:   found,
+	}
+}
+
+// report records an error without unwinding, for errors after which the
+// parser is not confused about where it is
+func (p *Parser) report(err *ParseError) {
+	p.errs = append(p.errs, *err)
+}
+
+// synchronize discards tokens until the start of the next statement, so that
+// one syntax error doesn't cause a cascade of others
+func (p *Parser) synchronize() {
+	p.advance()
+	for !p.isAtEnd() {
+		if p.previous().Type == TokenTypeSemicolon {
+			return
+		}
+		switch p.peek().Type {
+		case TokenTypeClass, TokenTypeConst, TokenTypeFun, TokenTypeVar, TokenTypeFor,
+			TokenTypeIf, TokenTypeWhile, TokenTypePrint, TokenTypeReturn, TokenTypeImport,
+			TokenTypeTry, TokenTypeThrow, TokenTypeBreak, TokenTypeContinue:
+			return
+		}
 		p.advance()
-		return nil
 	}
-	return fmt.Errorf("expected %s token, got %s", t, next)
 }
 
-func (p *Parser) Program() []Stmt {
+// Program parses every declaration in the token stream. Declarations with
+// syntax errors are left out of the returned statements, and an error is
+// returned for each of them.
+func (p *Parser) Program() ([]Stmt, []ParseError) {
 	stmts := []Stmt{}
 	for !p.isAtEnd() {
-		// Top-level short circuit: discard comments
-		for p.check(TokenTypeComment) {
-			p.advance()
-		}
-		if p.isAtEnd() {
-			break
+		if stmt := p.Decl(); stmt != nil {
+			stmts = append(stmts, stmt)
 		}
-		stmts = append(stmts, p.Decl())
 	}
-	return stmts
+	return stmts, p.errs
 }
 
-func (p *Parser) Decl() Stmt {
-	if p.match(TokenTypeFun) {
+// Decl parses a declaration or statement. If it contains a syntax error, the
+// error is recorded, the parser skips to the next statement and Decl returns
+// nil.
+func (p *Parser) Decl() (stmt Stmt) {
+	start := p.peek()
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*ParseError)
+			if !ok {
+				panic(r)
+			}
+			// A statement that fails to parse may start with a misspelled
+			// keyword, such as fucn or retrun, that scanned as an identifier
+			if err.Hint == "" && start.Type == TokenTypeIdentifier {
+				err.Hint = didYouMean(start.Lexeme, keywords())
+			}
+			p.report(err)
+			p.synchronize()
+			stmt = nil
+		}
+	}()
+
+	if p.match(TokenTypeClass) {
+		return p.ClassDecl()
+	}
+	// fun followed by a name is a declaration; otherwise it starts a function
+	// expression statement
+	if p.check(TokenTypeFun) && p.checkNext(TokenTypeIdentifier) {
+		p.advance()
 		return p.Function("function")
 	}
 	if p.match(TokenTypeVar) {
 		return p.VarDecl()
 	}
+	if p.match(TokenTypeConst) {
+		return p.ConstDecl()
+	}
+	if p.match(TokenTypeImport) {
+		return p.ImportDecl()
+	}
 	return p.Statement()
 }
 
-func (p *Parser) Function(kind string) Stmt {
-	if err := p.consume(TokenTypeIdentifier); err != nil {
-		panic(err)
+// ImportDecl parses import "path"; or import "path" as name;. Without a name,
+// the module is bound to its file name without the extension.
+func (p *Parser) ImportDecl() Stmt {
+	keyword := p.previous()
+	p.consume(TokenTypeString, "expected module path after 'import'")
+	path := p.previous()
+	var name Token
+	if p.check(TokenTypeIdentifier) && p.peek().Lexeme == "as" {
+		p.advance()
+		p.consume(TokenTypeIdentifier, "expected module name after 'as'")
+		name = p.previous()
+	} else {
+		base := filepath.Base(path.Literal.(string))
+		lexeme := strings.TrimSuffix(base, filepath.Ext(base))
+		if !isIdentifier(lexeme) {
+			panic(p.errorAt(path, "cannot name module %s after its file; use 'as' to name it", path.Lexeme))
+		}
+		name = Token{Type: TokenTypeIdentifier, Lexeme: lexeme, Pos: path.Pos}
+	}
+	p.consume(TokenTypeSemicolon, "expected ';' after import")
+	return ImportStmt{keyword: keyword, path: path, name: name}
+}
+
+func (p *Parser) ClassDecl() Stmt {
+	p.consume(TokenTypeIdentifier, "expected class name")
+	name := p.previous()
+	var superclass *Identifier
+	if p.match(TokenTypeLess) {
+		p.consume(TokenTypeIdentifier, "expected superclass name")
+		superclass = &Identifier{name: p.previous(), ref: newVarRef()}
+	}
+	p.consume(TokenTypeLeftBrace, "expected '{' before class body")
+
+	methods := []FuncDecl{}
+	for !p.check(TokenTypeRightBrace) && !p.isAtEnd() {
+		methods = append(methods, p.Function("method").(FuncDecl))
 	}
+
+	p.consume(TokenTypeRightBrace, "expected '}' after class body")
+	return ClassDecl{name: name, superclass: superclass, methods: methods}
+}
+
+func (p *Parser) Function(kind string) Stmt {
+	p.consume(TokenTypeIdentifier, "expected "+kind+" name")
 	name := p.previous()
-	if err := p.consume(TokenTypeLeftParen); err != nil {
-		panic(err)
+	params, body := p.functionBody(name)
+	return FuncDecl{
+		name:   name,
+		params: params,
+		body:   body,
 	}
+}
+
+// functionBody parses the parameter list and body shared by function
+// declarations and function expressions. name is used in error messages.
+func (p *Parser) functionBody(name Token) ([]Token, []Stmt) {
+	p.consume(TokenTypeLeftParen, "expected '(' after "+name.Lexeme)
 	params := []Token{}
 	if !p.check(TokenTypeRightParen) {
 		for {
 			if len(params) >= 255 {
-				panic(fmt.Errorf("cannot call %s with more than 255 parameters", name))
-			}
-			if err := p.consume(TokenTypeIdentifier); err != nil {
-				panic(fmt.Errorf("%s: expected parameter name", p.previous()))
+				p.report(p.errorAt(p.peek(), "cannot have more than 255 parameters"))
 			}
+			p.consume(TokenTypeIdentifier, "expected parameter name")
 			params = append(params, p.previous())
 			if !p.match(TokenTypeComma) {
 				break
 			}
 		}
 	}
-	if err := p.consume(TokenTypeRightParen); err != nil {
-		panic(err)
-	}
-	if err := p.consume(TokenTypeLeftBrace); err != nil {
-		panic(err)
-	}
+	p.consume(TokenTypeRightParen, "expected ')' after parameters")
+	p.consume(TokenTypeLeftBrace, "expected '{' before function body")
 	body := (p.Block()).(Block)
-	return FuncDecl{
-		name:   name,
-		params: params,
-		body:   body.statements,
-	}
+	return params, body.statements
 }
 
 func (p *Parser) VarDecl() Stmt {
-	identifier := p.advance()
+	identifier := p.consume(TokenTypeIdentifier, "expected variable name")
 	var initializer Expr
 	if p.match(TokenTypeEqual) {
 		initializer = p.Expression()
 	}
-	if err := p.consume(TokenTypeSemicolon); err != nil {
-		panic(err)
-	}
+	p.consume(TokenTypeSemicolon, "expected ';' after variable declaration")
 	return VarDecl{name: identifier, initializer: initializer}
 }
 
+// ConstDecl parses a const declaration, which unlike var must be initialized
+func (p *Parser) ConstDecl() Stmt {
+	p.consume(TokenTypeIdentifier, "expected constant name after 'const'")
+	identifier := p.previous()
+	if !p.match(TokenTypeEqual) {
+		panic(p.errorAt(p.peek(), "constant '%s' must be initialized", identifier.Lexeme))
+	}
+	initializer := p.Expression()
+	p.consume(TokenTypeSemicolon, "expected ';' after constant declaration")
+	return VarDecl{name: identifier, initializer: initializer, constant: true}
+}
+
 func (p *Parser) Statement() Stmt {
 	switch {
 	case p.match(TokenTypeIf):
@@ -154,10 +317,64 @@ func (p *Parser) Statement() Stmt {
 		return p.WhileStmt()
 	case p.match(TokenTypeFor):
 		return p.ForStmt()
+	case p.match(TokenTypeReturn):
+		return p.ReturnStmt()
+	case p.match(TokenTypeBreak):
+		keyword := p.previous()
+		p.consume(TokenTypeSemicolon, "expected ';' after 'break'")
+		return BreakStmt{keyword: keyword}
+	case p.match(TokenTypeThrow):
+		keyword := p.previous()
+		value := p.Expression()
+		p.consume(TokenTypeSemicolon, "expected ';' after thrown value")
+		return ThrowStmt{keyword: keyword, value: value}
+	case p.match(TokenTypeTry):
+		return p.TryStmt()
+	case p.match(TokenTypeContinue):
+		keyword := p.previous()
+		p.consume(TokenTypeSemicolon, "expected ';' after 'continue'")
+		return ContinueStmt{keyword: keyword}
 	}
 	return p.ExprStmt()
 }
 
+func (p *Parser) TryStmt() Stmt {
+	keyword := p.previous()
+	stmt := TryStmt{keyword: keyword}
+	p.consume(TokenTypeLeftBrace, "expected '{' after 'try'")
+	stmt.body = p.Block().(Block)
+
+	if p.match(TokenTypeCatch) {
+		p.consume(TokenTypeLeftParen, "expected '(' after 'catch'")
+		p.consume(TokenTypeIdentifier, "expected catch variable name")
+		name := p.previous()
+		stmt.catchName = &name
+		p.consume(TokenTypeRightParen, "expected ')' after catch variable")
+		p.consume(TokenTypeLeftBrace, "expected '{' after catch clause")
+		catchBody := p.Block().(Block)
+		stmt.catchBody = &catchBody
+	}
+	if p.match(TokenTypeFinally) {
+		p.consume(TokenTypeLeftBrace, "expected '{' after 'finally'")
+		finallyBody := p.Block().(Block)
+		stmt.finallyBody = &finallyBody
+	}
+	if stmt.catchBody == nil && stmt.finallyBody == nil {
+		panic(p.errorAt(keyword, "expected 'catch' or 'finally' after try block"))
+	}
+	return stmt
+}
+
+func (p *Parser) ReturnStmt() Stmt {
+	keyword := p.previous()
+	var value Expr
+	if !p.check(TokenTypeSemicolon) {
+		value = p.Expression()
+	}
+	p.consume(TokenTypeSemicolon, "expected ';' after return value")
+	return ReturnStmt{keyword: keyword, value: value}
+}
+
 func (p *Parser) IfStmt() Stmt {
 	// Don't consume left paren here, to support if statements without parens
 	condition := p.Expression()
@@ -174,29 +391,23 @@ func (p *Parser) IfStmt() Stmt {
 func (p *Parser) Block() Stmt {
 	statements := []Stmt{}
 	for !p.check(TokenTypeRightBrace) && !p.isAtEnd() {
-		statements = append(statements, p.Decl())
-	}
-	if err := p.consume(TokenTypeRightBrace); err != nil {
-		panic(err)
+		if stmt := p.Decl(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
+	p.consume(TokenTypeRightBrace, "expected '}' after block")
 	return Block{statements: statements}
 }
 
 func (p *Parser) ExprStmt() Stmt {
 	expr := p.Expression()
-	err := p.consume(TokenTypeSemicolon)
-	if err != nil {
-		panic(err)
-	}
+	p.consume(TokenTypeSemicolon, "expected ';' after expression")
 	return ExprStmt{expr: expr}
 }
 
 func (p *Parser) PrintStmt() Stmt {
 	expr := p.Expression()
-	err := p.consume(TokenTypeSemicolon)
-	if err != nil {
-		panic(err)
-	}
+	p.consume(TokenTypeSemicolon, "expected ';' after value")
 	return PrintStmt{expr: expr}
 }
 
@@ -212,8 +423,12 @@ func (p *Parser) WhileStmt() Stmt {
 }
 
 func (p *Parser) ForStmt() Stmt {
+	keyword := p.previous()
 	// consume left paren in case one's provided
 	p.match(TokenTypeLeftParen)
+	if p.check(TokenTypeIdentifier) && p.checkNext(TokenTypeIn) {
+		return p.ForInStmt(keyword)
+	}
 	var initializer Stmt
 	if p.match(TokenTypeSemicolon) {
 		initializer = nil
@@ -238,25 +453,17 @@ func (p *Parser) ForStmt() Stmt {
 	p.match(TokenTypeRightParen)
 
 	body := p.Statement()
-	if increment != nil {
-		body = Block{
-			statements: []Stmt{
-				body,
-				ExprStmt{
-					expr: increment,
-				},
-			},
-		}
-	}
-
 	if condition == nil {
 		condition = Literal{
 			value: true,
 		}
 	}
+	// The increment is kept separate from the body so that it still runs when
+	// the body ends with a continue
 	body = WhileStmt{
 		condition: condition,
 		body:      body,
+		increment: increment,
 	}
 
 	if initializer != nil {
@@ -270,19 +477,74 @@ func (p *Parser) ForStmt() Stmt {
 	return body
 }
 
+// ForInStmt parses the rest of a for (name in iterable) loop, after the left
+// paren
+func (p *Parser) ForInStmt(keyword Token) Stmt {
+	name := p.advance()
+	p.advance()
+	iterable := p.Expression()
+	// consume right paren in case one's provided, as ForStmt does
+	p.match(TokenTypeRightParen)
+	return ForInStmt{keyword: keyword, name: name, iterable: iterable, body: p.Statement()}
+}
+
 func (p *Parser) Expression() Expr {
 	return p.Assignment()
 }
 
 func (p *Parser) Assignment() Expr {
-	expr := p.LogicOr()
+	expr := p.Conditional()
 	if p.match(TokenTypeEqual) {
+		equals := p.previous()
 		val := p.Assignment()
-		if exprVar, ok := expr.(Identifier); ok {
-			name := exprVar.name
-			return Assign{name: name, val: val}
+		switch target := expr.(type) {
+		case Identifier:
+			return Assign{name: target.name, val: val, ref: target.ref}
+		case Get:
+			return Set{object: target.object, name: target.name, val: val}
+		case GetIndex:
+			return SetIndex{object: target.object, index: target.index, right: target.right, val: val}
 		}
-		panic(fmt.Errorf("invalid assign target %s (%s)", ExprToString(expr), expr.Pos()))
+		p.report(&ParseError{Message: fmt.Sprintf("invalid assign target %s", ExprToString(expr)), Pos: expr.Pos(), Found: equals})
+	}
+	if p.match(TokenTypePlusEqual, TokenTypeMinusEqual, TokenTypeStarEqual, TokenTypeSlashEqual, TokenTypePercentEqual) {
+		operator := p.previous()
+		val := p.Assignment()
+		return CompoundAssign{target: p.assignTarget(expr, operator), operator: operator, val: val}
+	}
+	return expr
+}
+
+// assignTarget checks that expr can be assigned to by operator
+func (p *Parser) assignTarget(expr Expr, operator Token) assignTarget {
+	target, ok := expr.(assignTarget)
+	if !ok {
+		p.report(&ParseError{Message: fmt.Sprintf("invalid %s target %s", operator.Lexeme, ExprToString(expr)), Pos: expr.Pos(), Found: operator})
+	}
+	return target
+}
+
+// Conditional parses cond ? a : b, which is right-associative so that
+// a ? b : c ? d : e groups as a ? b : (c ? d : e)
+func (p *Parser) Conditional() Expr {
+	expr := p.Coalesce()
+	if p.match(TokenTypeQuestion) {
+		question := p.previous()
+		thenBranch := p.Expression()
+		p.consume(TokenTypeColon, "expected ':' in conditional expression")
+		elseBranch := p.Conditional()
+		expr = Conditional{condition: expr, question: question, thenBranch: thenBranch, elseBranch: elseBranch}
+	}
+	return expr
+}
+
+// Coalesce parses a ?? b, which binds more loosely than or
+func (p *Parser) Coalesce() Expr {
+	expr := p.LogicOr()
+	if p.match(TokenTypeQuestionQuestion) {
+		operator := p.previous()
+		right := p.Coalesce()
+		expr = Logical{left: expr, operator: operator, right: right}
 	}
 	return expr
 }
@@ -319,7 +581,7 @@ func (p *Parser) Equality() Expr {
 
 func (p *Parser) Comparison() Expr {
 	expr := p.Term()
-	for p.match(TokenTypeGreater, TokenTypeEqualEqual, TokenTypeLess, TokenTypeLessEqual) {
+	for p.match(TokenTypeGreater, TokenTypeGreaterEqual, TokenTypeLess, TokenTypeLessEqual) {
 		operator := p.previous()
 		right := p.Term()
 		expr = BinaryExpr{left: expr, operator: operator, right: right}
@@ -339,7 +601,7 @@ func (p *Parser) Term() Expr {
 
 func (p *Parser) Factor() Expr {
 	expr := p.Unary()
-	for p.match(TokenTypeSlash, TokenTypeStar) {
+	for p.match(TokenTypeSlash, TokenTypeStar, TokenTypePercent, TokenTypeTildeSlash) {
 		operator := p.previous()
 		right := p.Unary()
 		expr = BinaryExpr{left: expr, operator: operator, right: right}
@@ -353,7 +615,34 @@ func (p *Parser) Unary() Expr {
 		right := p.Unary()
 		return UnaryExpr{operator: operator, right: right}
 	}
-	return p.Call()
+	if p.match(TokenTypePlusPlus, TokenTypeMinusMinus) {
+		operator := p.previous()
+		target := p.assignTarget(p.Unary(), operator)
+		return Update{target: target, operator: operator, prefix: true}
+	}
+	return p.Power()
+}
+
+// Power parses exponentiation, which is right-associative and binds tighter
+// than a unary operator on its left, so -2 ** 2 is -(2 ** 2). The exponent may
+// itself be a unary expression, as in 2 ** -1.
+func (p *Parser) Power() Expr {
+	expr := p.Postfix()
+	if p.match(TokenTypeStarStar) {
+		operator := p.previous()
+		right := p.Unary()
+		expr = BinaryExpr{left: expr, operator: operator, right: right}
+	}
+	return expr
+}
+
+func (p *Parser) Postfix() Expr {
+	expr := p.Call()
+	if p.match(TokenTypePlusPlus, TokenTypeMinusMinus) {
+		operator := p.previous()
+		expr = Update{target: p.assignTarget(expr, operator), operator: operator, prefix: false}
+	}
+	return expr
 }
 
 func (p *Parser) finishCall(callee Expr) Expr {
@@ -365,14 +654,11 @@ func (p *Parser) finishCall(callee Expr) Expr {
 				break
 			}
 			if len(args) > 255 {
-				panic(fmt.Errorf("cannot exceed 255 arguments: %s", p.previous()))
+				p.report(p.errorAt(p.peek(), "cannot have more than 255 arguments"))
 			}
 		}
 	}
-	err := p.consume(TokenTypeRightParen)
-	if err != nil {
-		panic(err)
-	}
+	p.consume(TokenTypeRightParen, "expected ')' after arguments")
 	return Call{
 		callee: callee,
 		paren:  p.previous(),
@@ -385,6 +671,13 @@ func (p *Parser) Call() Expr {
 	for {
 		if p.match(TokenTypeLeftParen) {
 			expr = p.finishCall(expr)
+		} else if p.match(TokenTypeDot) {
+			p.consume(TokenTypeIdentifier, "expected property name after '.'")
+			expr = Get{object: expr, name: p.previous()}
+		} else if p.match(TokenTypeLeftBracket) {
+			index := p.Expression()
+			p.consume(TokenTypeRightBracket, "expected ']' after index")
+			expr = GetIndex{object: expr, index: index, right: p.previous()}
 		} else {
 			break
 		}
@@ -402,41 +695,107 @@ func (p *Parser) Primary() Expr {
 		return Literal{token: p.previous(), value: nil}
 	case p.match(TokenTypeString):
 		return Literal{token: p.previous(), value: p.previous().Literal}
+	case p.match(TokenTypeInterpolation):
+		return p.interpolation()
 	case p.match(TokenTypeNumber):
-		nStr, ok := p.previous().Literal.(string)
-		if !ok {
-			panic(fmt.Errorf("expected unparsed number, got %s", p.previous()))
-		}
-		n, err := strconv.ParseFloat(nStr, 64)
-		if err != nil {
-			panic(fmt.Errorf("expected number, got %s", p.previous()))
-		}
-		return Literal{token: p.previous(), value: n}
+		return Literal{token: p.previous(), value: p.previous().Literal}
 	case p.match(TokenTypeLeftParen):
 		leftParen := p.previous()
 		expr := p.Expression()
-		err := p.consume(TokenTypeRightParen)
-		if err != nil {
-			// TODO: sync to next statement boundary (semicolon, keyword)
-			panic(err)
-		}
+		p.consume(TokenTypeRightParen, "expected ')' after expression")
 		return Grouping{left: leftParen, expr: expr, right: p.previous()}
 	case p.match(TokenTypeIdentifier):
-		return Identifier{name: p.previous()}
+		return Identifier{name: p.previous(), ref: newVarRef()}
+	case p.match(TokenTypeLeftBracket):
+		left := p.previous()
+		elements := []Expr{}
+		for !p.check(TokenTypeRightBracket) {
+			elements = append(elements, p.Expression())
+			if !p.match(TokenTypeComma) {
+				break
+			}
+		}
+		p.consume(TokenTypeRightBracket, "expected ']' after list elements")
+		return ListExpr{left: left, elements: elements, right: p.previous()}
+	case p.match(TokenTypeLeftBrace):
+		// Statement always treats a leading { as a block, so a map literal is
+		// only parsed where an expression is expected. A statement that starts
+		// with a map literal must wrap it in parentheses.
+		return p.mapLiteral()
+	case p.match(TokenTypeFun):
+		keyword := p.previous()
+		params, body := p.functionBody(keyword)
+		return FuncExpr{keyword: keyword, params: params, body: body}
+	case p.match(TokenTypeThis):
+		return This{keyword: p.previous(), ref: newVarRef()}
+	case p.match(TokenTypeSuper):
+		keyword := p.previous()
+		p.consume(TokenTypeDot, "expected '.' after 'super'")
+		p.consume(TokenTypeIdentifier, "expected superclass method name")
+		return Super{keyword: keyword, method: p.previous(), ref: newVarRef()}
+	}
+	panic(p.errorAt(p.peek(), "expected expression, got %s", describe(p.peek())))
+}
+
+// interpolation parses the rest of an interpolated string, alternating
+// between string parts and expressions until the final string token
+func (p *Parser) interpolation() Expr {
+	parts := []Expr{Literal{token: p.previous(), value: p.previous().Literal}}
+	for {
+		parts = append(parts, p.Expression())
+		if p.match(TokenTypeInterpolation) {
+			parts = append(parts, Literal{token: p.previous(), value: p.previous().Literal})
+			continue
+		}
+		p.consume(TokenTypeString, "expected '}' after interpolated expression")
+		parts = append(parts, Literal{token: p.previous(), value: p.previous().Literal})
+		return Interpolation{parts: parts}
 	}
-	panic(fmt.Errorf("expected expression, got %s", p.peek()))
 }
 
-func (p *Parser) Execute(env *Environment) {
-	env.Declare("clock", ClockFunc{})
-	statements := p.Program()
+func (p *Parser) mapLiteral() Expr {
+	left := p.previous()
+	keys := []Expr{}
+	values := []Expr{}
+	for !p.check(TokenTypeRightBrace) {
+		keys = append(keys, p.Expression())
+		p.consume(TokenTypeColon, "expected ':' after map key")
+		values = append(values, p.Expression())
+		if !p.match(TokenTypeComma) {
+			break
+		}
+	}
+	p.consume(TokenTypeRightBrace, "expected '}' after map entries")
+	return MapExpr{left: left, keys: keys, values: values, right: p.previous()}
+}
+
+// Execute parses, resolves and runs the program in env, returning the first
+// error that stops it. Errors raised while running are *RuntimeError values.
+func (p *Parser) Execute(env *Environment) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = uncaughtError(r)
+		}
+	}()
+	usePrelude(env)
+	statements, errs := p.Program()
+	if len(errs) > 0 {
+		return ParseErrors(errs)
+	}
+	if err := NewResolver().Resolve(statements); err != nil {
+		return err
+	}
 	for _, stmt := range statements {
 		stmt.Execute(env)
 	}
+	return nil
 }
 
 func (p *Parser) PrintAST() {
-	statements := p.Program()
+	statements, errs := p.Program()
+	for _, err := range errs {
+		fmt.Println(err)
+	}
 	for _, stmt := range statements {
 		fmt.Println(StmtToString(stmt))
 	}
@@ -0,0 +1,906 @@
+package glox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// runSource executes source in a fresh environment and returns it, so tests can
+// inspect the globals a script leaves behind.
+func runSource(t *testing.T, source string) *Environment {
+	t.Helper()
+	tokens, err := NewScanner([]byte(source)).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnvironment(nil)
+	if err := NewParser(tokens).Execute(env); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func expectGlobal(t *testing.T, env *Environment, name string, expected any) {
+	t.Helper()
+	v, ok := env.Get(name)
+	if !ok {
+		t.Fatalf("Expected global %s to be declared", name)
+	}
+	if !isEqual(v, expected) {
+		t.Errorf("Expected %s to be %v, got %v", name, expected, v)
+	}
+}
+
+func TestReturn(t *testing.T) {
+	env := runSource(t, `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+fun firstOver(limit) {
+  var i = 0;
+  while (true) {
+    {
+      if (i > limit) {
+        return i;
+      }
+    }
+    i = i + 1;
+  }
+}
+fun nothing() {
+  return;
+}
+var a = fib(10);
+var b = firstOver(4);
+var c = nothing();
+`)
+	expectGlobal(t, env, "a", 55.0)
+	expectGlobal(t, env, "b", 5.0)
+	expectGlobal(t, env, "c", nil)
+}
+
+func TestClosures(t *testing.T) {
+	env := runSource(t, `
+fun makeCounter() {
+  var i = 0;
+  fun count() {
+    i = i + 1;
+    return i;
+  }
+  return count;
+}
+var counter = makeCounter();
+counter();
+var a = counter();
+var other = makeCounter();
+var b = other();
+
+var x = "global";
+fun showX() {
+  return x;
+}
+fun shadow() {
+  var x = "local";
+  return showX();
+}
+var c = shadow();
+`)
+	expectGlobal(t, env, "a", 2.0)
+	expectGlobal(t, env, "b", 1.0)
+	expectGlobal(t, env, "c", "global")
+}
+
+func TestClasses(t *testing.T) {
+	env := runSource(t, `
+class Counter {
+  init(start) {
+    this.count = start;
+  }
+  incr() {
+    this.count = this.count + 1;
+    return this;
+  }
+  get() {
+    return this.count;
+  }
+}
+var c = Counter(10);
+c.incr().incr();
+var a = c.get();
+var get = c.get;
+c.incr();
+var b = get();
+var same = c.init(3) == c;
+var d = c.count;
+`)
+	expectGlobal(t, env, "a", 12.0)
+	expectGlobal(t, env, "b", 13.0)
+	expectGlobal(t, env, "same", true)
+	expectGlobal(t, env, "d", 3.0)
+}
+
+func TestInheritance(t *testing.T) {
+	env := runSource(t, `
+class Shape {
+  init(name) {
+    this.name = name;
+  }
+  describe() {
+    return "a " + this.name;
+  }
+  kind() {
+    return "shape";
+  }
+}
+class Square < Shape {
+  init(side) {
+    super.init("square");
+    this.side = side;
+  }
+  describe() {
+    return super.describe() + " of side " + this.kindOfSide();
+  }
+  kindOfSide() {
+    return "n";
+  }
+}
+class Tiny < Square {}
+var sq = Tiny(2);
+var a = sq.describe();
+var b = sq.kind();
+var c = sq.side;
+`)
+	expectGlobal(t, env, "a", "a square of side n")
+	expectGlobal(t, env, "b", "shape")
+	expectGlobal(t, env, "c", 2.0)
+}
+
+func TestPropertyChains(t *testing.T) {
+	env := runSource(t, `
+class Node {
+  init(value) {
+    this.value = value;
+    this.next = nil;
+  }
+  append(value) {
+    this.next = Node(value);
+    return this.next;
+  }
+}
+var head = Node(1);
+head.append(2).append(3);
+head.next.next.value = 30;
+var a = head.next.next.value;
+var b = head.next.value;
+`)
+	expectGlobal(t, env, "a", 30.0)
+	expectGlobal(t, env, "b", 2.0)
+}
+
+func TestPropertyPos(t *testing.T) {
+	tokens, err := NewScanner([]byte("a.b.c = x;")).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, _ := NewParser(tokens).Program()
+	set := stmts[0].(ExprStmt).expr.(Set)
+	if pos := set.Pos(); pos.Start != 0 || pos.End != 9 {
+		t.Errorf("Expected set to span [0:9], got %s", pos)
+	}
+	if pos := set.object.Pos(); pos.Start != 0 || pos.End != 3 {
+		t.Errorf("Expected get to span [0:3], got %s", pos)
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	env := runSource(t, `
+var sum = 0;
+for (var i = 0; i < 10; i = i + 1) {
+  if (i == 2) continue;
+  if (i == 5) break;
+  sum = sum + i;
+}
+var count = 0;
+var j = 0;
+while (true) {
+  j = j + 1;
+  if (j > 3) {
+    break;
+  }
+  {
+    continue;
+  }
+  count = 100;
+}
+`)
+	expectGlobal(t, env, "sum", 1.0+3.0+4.0)
+	expectGlobal(t, env, "j", 4.0)
+	expectGlobal(t, env, "count", 0.0)
+}
+
+func TestFunctionExpressions(t *testing.T) {
+	env := runSource(t, `
+fun apply(f, x) {
+  return f(x);
+}
+fun adder(n) {
+  return fun (x) { return x + n; };
+}
+var double = fun (x) { return x * 2; };
+var a = apply(double, 4);
+var b = apply(fun (x) { return x - 1; }, 4);
+var c = adder(10)(5);
+var d = fun () { return "iife"; }();
+`)
+	expectGlobal(t, env, "a", 8.0)
+	expectGlobal(t, env, "b", 3.0)
+	expectGlobal(t, env, "c", 15.0)
+	expectGlobal(t, env, "d", "iife")
+
+	double, _ := env.Get("double")
+	if s := double.(*DefinedFunc).String(); s != "<fn anonymous@line 8>" {
+		t.Errorf("Expected anonymous function name, got %s", s)
+	}
+}
+
+func TestLists(t *testing.T) {
+	env := runSource(t, `
+var xs = [1, 2, 3,];
+xs[0] = 10;
+append(xs, 4);
+insert(xs, 1, "a");
+var last = pop(xs);
+var n = len(xs);
+var first = xs[0];
+var second = xs[1];
+var part = slice(xs, 1, 3);
+var partLen = len(part);
+var same = xs == xs;
+var different = [] == [];
+var nested = [[1], [2]];
+nested[1][0] = 5;
+var inner = nested[1][0];
+`)
+	expectGlobal(t, env, "last", 4.0)
+	expectGlobal(t, env, "n", 4.0)
+	expectGlobal(t, env, "first", 10.0)
+	expectGlobal(t, env, "second", "a")
+	expectGlobal(t, env, "partLen", 2.0)
+	expectGlobal(t, env, "same", true)
+	expectGlobal(t, env, "different", false)
+	expectGlobal(t, env, "inner", 5.0)
+
+	part, _ := env.Get("part")
+	if s := stringify(part); s != `["a", 2]` {
+		t.Errorf("Expected list to print as [\"a\", 2], got %s", s)
+	}
+
+	// Collections that contain themselves print the repeat as ... rather than
+	// recursing forever, while a collection repeated side by side prints in full
+	env = runSource(t, `
+var a = [1];
+append(a, a);
+var m = {"a": a};
+m["m"] = m;
+var twice = [[1], nil];
+twice[1] = twice[0];
+`)
+	for name, expected := range map[string]string{
+		"a":     `[1, [...]]`,
+		"m":     `{"a": [1, [...]], "m": {...}}`,
+		"twice": `[[1], [1]]`,
+	} {
+		v, _ := env.Get(name)
+		if s := stringify(v); s != expected {
+			t.Errorf("Expected %s to print as %s, got %s", name, expected, s)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	env := runSource(t, `
+var m = {"b": 1, "a": 2, 3: "three", true: nil,};
+m["c"] = 3;
+m["b"] = 10;
+var b = m["b"];
+var three = m[3];
+var hasTrue = has(m, true);
+var deleted = delete(m, "a");
+var hasA = has(m, "a");
+var ks = keys(m);
+var n = len(m);
+var empty = len({});
+`)
+	expectGlobal(t, env, "b", 10.0)
+	expectGlobal(t, env, "three", "three")
+	expectGlobal(t, env, "hasTrue", true)
+	expectGlobal(t, env, "deleted", true)
+	expectGlobal(t, env, "hasA", false)
+	expectGlobal(t, env, "n", 4.0)
+	expectGlobal(t, env, "empty", 0.0)
+
+	ks, _ := env.Get("ks")
+	if s := stringify(ks); s != `["b", 3, true, "c"]` {
+		t.Errorf("Expected keys in insertion order, got %s", s)
+	}
+}
+
+func TestCommentsAnywhere(t *testing.T) {
+	env := runSource(t, `
+fun /* name */ add(a, // first
+  b /* second */) {
+  // body comment
+  return a /* inline */ + b;
+  /* trailing */
+}
+var sum = add(1, 2); // done
+`)
+	expectGlobal(t, env, "sum", 3.0)
+}
+
+func TestArithmeticOperators(t *testing.T) {
+	env := runSource(t, `
+var mod = 7 % 3;
+var negMod = -7 % 3;
+var floorDiv = 7 ~/ 2;
+var negFloorDiv = -7 ~/ 2;
+var pow = 2 ** 10;
+var rightAssoc = 2 ** 3 ** 2;
+var negPow = -2 ** 2;
+var negExponent = 2 ** -1;
+var precedence = 1 + 2 * 3 ** 2 % 5;
+var ge = 3 >= 3;
+`)
+	expectGlobal(t, env, "mod", 1.0)
+	expectGlobal(t, env, "negMod", 2.0)
+	expectGlobal(t, env, "floorDiv", 3.0)
+	expectGlobal(t, env, "negFloorDiv", -4.0)
+	expectGlobal(t, env, "pow", 1024.0)
+	expectGlobal(t, env, "rightAssoc", 512.0)
+	expectGlobal(t, env, "negPow", -4.0)
+	expectGlobal(t, env, "negExponent", 0.5)
+	expectGlobal(t, env, "precedence", 4.0)
+	expectGlobal(t, env, "ge", true)
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	env := runSource(t, `
+var x = 10;
+x += 5;
+x -= 3;
+x *= 2;
+x /= 4;
+x %= 4;
+var s = "a";
+s += "b";
+
+var i = 0;
+var post = i++;
+var pre = ++i;
+var postDec = i--;
+var preDec = --i;
+
+var calls = 0;
+var xs = [1, 2, 3];
+fun idx() {
+  calls++;
+  return 1;
+}
+xs[idx()] += 10;
+xs[idx()]++;
+
+class Box {}
+var box = Box();
+box.n = 1;
+box.n *= 7;
+var boxPost = box.n--;
+var m = {"k": 1};
+m["k"] += 1;
+`)
+	expectGlobal(t, env, "x", 2.0)
+	expectGlobal(t, env, "s", "ab")
+	expectGlobal(t, env, "post", 0.0)
+	expectGlobal(t, env, "pre", 2.0)
+	expectGlobal(t, env, "postDec", 2.0)
+	expectGlobal(t, env, "preDec", 0.0)
+	expectGlobal(t, env, "i", 0.0)
+	expectGlobal(t, env, "calls", 2.0)
+	expectGlobal(t, env, "boxPost", 7.0)
+
+	xs, _ := env.Get("xs")
+	if s := stringify(xs); s != "[1, 13, 3]" {
+		t.Errorf("Expected [1, 13, 3], got %s", s)
+	}
+	m, _ := env.Get("m")
+	if s := stringify(m); s != `{"k": 2}` {
+		t.Errorf("Expected {\"k\": 2}, got %s", s)
+	}
+
+	// The right-hand side can shrink the list after the element is read
+	tokens, err := NewScanner([]byte("var a = [1];\na[0] += pop(a);")).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewParser(tokens).Execute(NewEnvironment(nil))
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != ErrorKindIndex || rerr.Pos.Line != 1 {
+		t.Errorf("Expected an IndexError on line 2, got %v", err)
+	}
+}
+
+func TestConditionalAndCoalesce(t *testing.T) {
+	env := runSource(t, `
+var calls = 0;
+fun count(v) {
+  calls++;
+  return v;
+}
+var a = true ? "yes" : "no";
+var b = false ? 1 : nil ? 2 : 3;
+var c = nil ?? "default";
+var d = false ?? count("unused");
+var e = nil ?? nil ?? "last";
+var f = nil ?? false or true;
+var g;
+g = 1 > 2 ? "big" : "small";
+var h = true ? count(1) : count(2);
+`)
+	expectGlobal(t, env, "a", "yes")
+	expectGlobal(t, env, "b", 3.0)
+	expectGlobal(t, env, "c", "default")
+	expectGlobal(t, env, "d", false)
+	expectGlobal(t, env, "e", "last")
+	expectGlobal(t, env, "f", true)
+	expectGlobal(t, env, "g", "small")
+	expectGlobal(t, env, "calls", 1.0)
+}
+
+func TestConditionalToString(t *testing.T) {
+	tokens, err := NewScanner([]byte("x = a ?? b ? c : d ? e : f;")).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, _ := NewParser(tokens).Program()
+	stmt := stmts[0].(ExprStmt)
+	expected := "(set x (?: (?? (id a) (id b)) (id c) (?: (id d) (id e) (id f))))"
+	if s := ExprToString(stmt.expr); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	if pos := stmt.expr.Pos(); pos.Start != 0 || pos.End != 26 {
+		t.Errorf("Expected assignment to span [0:26], got %s", pos)
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	env := runSource(t, `
+var log = [];
+try {
+  throw "boom";
+} catch (e) {
+  append(log, "caught ${e}");
+} finally {
+  append(log, "finally");
+}
+
+var kind;
+var message;
+var line;
+try {
+  var x = 1 + nil;
+} catch (e) {
+  kind = e.kind;
+  message = e.message;
+  line = e.line;
+}
+
+fun early() {
+  try {
+    return "returned";
+  } finally {
+    append(log, "finally after return");
+  }
+}
+var r = early();
+
+for (var i = 0; i < 3; i++) {
+  try {
+    if (i == 1) break;
+  } finally {
+    append(log, "loop ${i}");
+  }
+}
+
+fun inner() {
+  try {
+    throw "uncaught";
+  } finally {
+    append(log, "finally before rethrow");
+  }
+}
+try {
+  inner();
+} catch (e) {
+  append(log, "outer ${e}");
+}
+
+var rethrown;
+var shown;
+try {
+  try {
+    [][0];
+  } catch (e) {
+    throw e;
+  }
+} catch (e) {
+  rethrown = e.kind;
+  shown = "${e}";
+}
+`)
+	expectGlobal(t, env, "kind", ErrorKindType)
+	expectGlobal(t, env, "message", "operands of '+' must be two numbers or two strings, got number and nil")
+	expectGlobal(t, env, "line", 15.0)
+	expectGlobal(t, env, "r", "returned")
+	expectGlobal(t, env, "rethrown", ErrorKindIndex)
+	expectGlobal(t, env, "shown", "IndexError: list index 0 out of range for length 0")
+
+	log, _ := env.Get("log")
+	expected := `["caught boom", "finally", "finally after return", "loop 0", "loop 1", "finally before rethrow", "outer uncaught"]`
+	if s := stringify(log); s != expected {
+		t.Errorf("Expected log %s, got %s", expected, s)
+	}
+}
+
+func TestConstants(t *testing.T) {
+	env := runSource(t, `
+const limit = 10;
+var shadowed;
+{
+  var limit = 1;
+  limit = 2;
+  shadowed = limit;
+}
+
+fun bump() {
+  late = late + 1;
+}
+const late = 0;
+var message;
+try {
+  bump();
+} catch (e) {
+  message = e.message;
+}
+`)
+	expectGlobal(t, env, "limit", 10.0)
+	expectGlobal(t, env, "shadowed", 2.0)
+	expectGlobal(t, env, "late", 0.0)
+	expectGlobal(t, env, "message", "cannot assign to constant 'late'")
+
+	tokens, err := NewScanner([]byte("const a;")).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := NewParser(tokens).Program()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "must be initialized") {
+		t.Errorf("Expected error for const without initializer, got %v", errs)
+	}
+}
+
+func TestForIn(t *testing.T) {
+	env := runSource(t, `
+var chars = [];
+for (c in "héllo") append(chars, c);
+
+var evens = [];
+for (n in range(0, 10, 2)) append(evens, n);
+var down = [];
+for (n in range(3, 0, -1)) append(down, n);
+var ones = [];
+for n in range(0, 3) append(ones, n);
+var tenths = [];
+for (n in range(0, 1, 0.1)) append(tenths, n);
+var tenthsLen = len(tenths);
+var third = tenths[2];
+var last = tenths[9];
+
+var items = [];
+for (x in [1, 2, 3]) {
+  if (x == 2) continue;
+  append(items, x);
+}
+
+var ages = {"ann": 30, "bob": 25};
+var names = [];
+for (name in ages) append(names, name);
+
+var closures = [];
+for (i in range(0, 3, 1)) {
+  append(closures, fun () { return i; });
+}
+var captured = [];
+for (f in closures) append(captured, f());
+
+class Countdown {
+  init(from) { this.from = from; }
+  iterator() { return CountdownIter(this.from); }
+}
+class CountdownIter {
+  init(n) { this.n = n; }
+  hasNext() { return this.n > 0; }
+  next() {
+    this.n--;
+    return this.n + 1;
+  }
+}
+var counted = [];
+for (n in Countdown(3)) {
+  if (n == 1) break;
+  append(counted, n);
+}
+var direct = [];
+for (n in CountdownIter(2)) append(direct, n);
+`)
+	expectGlobal(t, env, "tenthsLen", 10.0)
+	expectGlobal(t, env, "third", 0.2)
+	expectGlobal(t, env, "last", 0.9)
+	for name, expected := range map[string]string{
+		"chars":    `["h", "é", "l", "l", "o"]`,
+		"evens":    `[0, 2, 4, 6, 8]`,
+		"down":     `[3, 2, 1]`,
+		"ones":     `[0, 1, 2]`,
+		"items":    `[1, 3]`,
+		"names":    `["ann", "bob"]`,
+		"captured": `[0, 1, 2]`,
+		"counted":  `[3, 2]`,
+		"direct":   `[2, 1]`,
+	} {
+		v, _ := env.Get(name)
+		if s := stringify(v); s != expected {
+			t.Errorf("Expected %s to be %s, got %s", name, expected, s)
+		}
+	}
+}
+
+func TestForInErrors(t *testing.T) {
+	for source, expected := range map[string]string{
+		"for (x in 1) {}":              "cannot iterate over number",
+		"range(0, 1, 0);":              "step must not be zero",
+		"range(0);":                    "expected 2 to 3 args but got 1",
+		"class A {} for (x in A()) {}": "has no hasNext() method",
+		"class A { iterator() { return 1; } }\nfor (x in A()) {}": "iterator() must return an instance",
+	} {
+		tokens, err := NewScanner([]byte(source)).ScanTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewParser(tokens).Execute(NewEnvironment(nil))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q for %q, got %v", expected, source, err)
+		}
+	}
+}
+
+func TestIteratorCalls(t *testing.T) {
+	for source, expected := range map[string]struct {
+		kind  string
+		trace []string
+	}{
+		// Protocol methods count toward the call depth
+		"class I {\n  hasNext() { for (x in this) {} return false; }\n  next() { return 1; }\n}\nfor (x in I()) {}": {ErrorKindStackOverflow, nil},
+		// and add a frame where the loop calls them
+		"class I {\n  hasNext() { return true; }\n  next() { return [][0]; }\n}\nfor (x in I()) {}": {ErrorKindIndex, []string{"next"}},
+	} {
+		tokens, err := NewScanner([]byte(source)).ScanTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewParser(tokens).Execute(NewEnvironment(nil))
+		rerr, ok := err.(*RuntimeError)
+		if !ok || rerr.Kind != expected.kind {
+			t.Errorf("Expected a %s for %q, got %v", expected.kind, source, err)
+			continue
+		}
+		if expected.trace == nil {
+			continue
+		}
+		if len(rerr.Trace) != len(expected.trace) {
+			t.Errorf("Expected %d frames for %q, got %+v", len(expected.trace), source, rerr.Trace)
+			continue
+		}
+		for i, name := range expected.trace {
+			if frame := rerr.Trace[i]; frame.Function != name || frame.Pos.Line != 4 {
+				t.Errorf("Expected frame %s on line 5, got %+v", name, frame)
+			}
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	for source, expected := range map[string]struct {
+		kind, message, token string
+	}{
+		`1 + "a";`:                        {ErrorKindType, "operands of '+' must be two numbers or two strings, got number and string", "+"},
+		`-"x";`:                           {ErrorKindType, "operand of '-' must be a number, got string", "-"},
+		"nil < 3;":                        {ErrorKindType, "operands of '<' must be numbers, got nil and number", "<"},
+		"print undefined;":                {ErrorKindName, "undefined variable 'undefined'", "undefined"},
+		"cuont = 1;":                      {ErrorKindName, "undefined variable 'cuont'", "cuont"},
+		`throw "boom";`:                   {ErrorKindError, "uncaught exception: boom", ""},
+		"var a = [1];\na[2];":             {ErrorKindIndex, "list index 2 out of range for length 1", ""},
+		"var xs = [1];\nxs[0] = pop(xs);": {ErrorKindIndex, "list index 0 out of range for length 0", ""},
+		"var m = {};\nm[0/0] = 1;":        {ErrorKindType, "NaN cannot be used as a map key", ""},
+		"var m = {0/0: 1};":               {ErrorKindType, "NaN cannot be used as a map key", ""},
+		"has({}, 0/0);":                   {ErrorKindType, "NaN cannot be used as a map key", ""},
+	} {
+		tokens, err := NewScanner([]byte(source)).ScanTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewParser(tokens).Execute(NewEnvironment(nil))
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("Expected a RuntimeError for %q, got %v", source, err)
+			continue
+		}
+		if rerr.Kind != expected.kind || rerr.Message != expected.message || rerr.Token.Lexeme != expected.token {
+			t.Errorf("Expected %s %q at %q for %q, got %s %q at %q", expected.kind, expected.message, expected.token, source, rerr.Kind, rerr.Message, rerr.Token.Lexeme)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tokens, err := NewScanner([]byte(`
+var a = ;
+print "fine";
+fun f( { }
+var b = (1 + 2;
+{
+  var c = 1
+  print c;
+}
+1 = 2;
+print "end"`)).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, errs := NewParser(tokens).Program()
+	expected := []struct {
+		message  string
+		line     int
+		expected TokenType
+		found    string
+	}{
+		{"expected expression, got ';'", 1, TokenTypeNone, ";"},
+		{"expected parameter name, got '{'", 3, TokenTypeIdentifier, "{"},
+		{"expected ')' after expression, got ';'", 4, TokenTypeRightParen, ";"},
+		{"expected ';' after variable declaration, got 'print'", 7, TokenTypeSemicolon, "print"},
+		{"invalid assign target 1", 9, TokenTypeNone, "="},
+		{"expected ';' after value, got end of file", 10, TokenTypeSemicolon, ""},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, e := range expected {
+		err := errs[i]
+		if err.Message != e.message || err.Pos.Line != e.line || err.Expected != e.expected || err.Found.Lexeme != e.found {
+			t.Errorf("Expected error %q on line %d expecting %s at %q, got %q on line %d expecting %s at %q",
+				e.message, e.line, e.expected, e.found, err.Message, err.Pos.Line, err.Expected, err.Found.Lexeme)
+		}
+	}
+	// The statements without errors are still parsed
+	if len(stmts) != 3 {
+		t.Errorf("Expected 3 statements, got %d", len(stmts))
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	tokens, err := NewScanner([]byte(`
+fun countdown(n) {
+  if (n == 0) throw "liftoff";
+  countdown(n - 1);
+}
+class Rocket {
+  init() { this.launch(); }
+  launch() { countdown(3); }
+}
+var go = fun () { Rocket(); };
+go();
+`)).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewParser(tokens).Execute(NewEnvironment(nil))
+	rerr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("Expected a RuntimeError, got %v", err)
+	}
+	expected := `at countdown (line 4) [repeated 2 more times]
+at countdown (line 8)
+at launch (line 7)
+at Rocket (line 10)
+at anonymous (line 11)`
+	if trace := rerr.StackTrace(0); trace != expected {
+		t.Errorf("Expected trace\n%s\ngot\n%s", expected, trace)
+	}
+	expected = `at countdown (line 4) [repeated 2 more times]
+at countdown (line 8)
+... 3 more frames`
+	if trace := rerr.StackTrace(2); trace != expected {
+		t.Errorf("Expected trace\n%s\ngot\n%s", expected, trace)
+	}
+}
+
+func TestFunctionEquality(t *testing.T) {
+	env := runSource(t, `
+fun f() {}
+fun g() {}
+var same = f == f;
+var different = f == g;
+var anon = fun () {};
+var sameAnon = anon == anon;
+class A { m() {} }
+var a = A();
+var bound = a.m == a.m;
+var method = a.m;
+var sameBound = method == method;
+`)
+	expectGlobal(t, env, "same", true)
+	expectGlobal(t, env, "different", false)
+	expectGlobal(t, env, "sameAnon", true)
+	// Each property access binds a new method, as in jlox
+	expectGlobal(t, env, "bound", false)
+	expectGlobal(t, env, "sameBound", true)
+}
+
+func TestStackOverflow(t *testing.T) {
+	tokens, err := NewScanner([]byte(`
+fun down(n) { return down(n + 1); }
+down(0);`)).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewParser(tokens).Execute(NewEnvironment(nil))
+	rerr, ok := err.(*RuntimeError)
+	if !ok || rerr.Kind != ErrorKindStackOverflow {
+		t.Fatalf("Expected a StackOverflowError, got %v", err)
+	}
+	if len(rerr.Trace) != maxCallDepth {
+		t.Errorf("Expected %d frames, got %d", maxCallDepth, len(rerr.Trace))
+	}
+	expected := fmt.Sprintf("at down (line 2) [repeated %d more times]\nat down (line 3)", maxCallDepth-2)
+	if trace := rerr.StackTrace(0); trace != expected {
+		t.Errorf("Expected trace\n%s\ngot\n%s", expected, trace)
+	}
+
+	// The depth is unwound, so a caught overflow leaves room for more calls
+	env := runSource(t, `
+fun down(n) { return down(n + 1); }
+var caught;
+try { down(0); } catch (e) { caught = e.kind; }
+fun one() { return 1; }
+var after = one();
+`)
+	expectGlobal(t, env, "caught", ErrorKindStackOverflow)
+	expectGlobal(t, env, "after", 1.0)
+}
+
+func TestShadowBuiltins(t *testing.T) {
+	env := runSource(t, `
+var values = [1, 2];
+class keys {}
+fun len(x) { return 99; }
+var n = len(values);
+var m = has({"a": 1}, "a");
+`)
+	expectGlobal(t, env, "n", 99.0)
+	expectGlobal(t, env, "m", true)
+
+	tokens, err := NewScanner([]byte("fun f() {}\nfun f() {}")).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewParser(tokens).Execute(NewEnvironment(nil))
+	if err == nil || !strings.Contains(err.Error(), "redeclaration of var f (line 2") {
+		t.Errorf("Expected redeclaration error for f, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package glox
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// SourceFunc returns the contents of a file reported in a diagnostic, or nil
+// if it is unavailable. Sources are used to count columns in characters rather
+// than bytes.
+type SourceFunc func(file string) []byte
+
+// region is a span of a source file, with one-based lines and columns. The end
+// column is the column after the span's last character.
+type region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func newRegion(pos Pos, source []byte) *region {
+	return &region{
+		StartLine:   pos.Line + 1,
+		StartColumn: charColumn(source, pos.Line, pos.Start),
+		EndLine:     pos.Line + 1,
+		EndColumn:   charColumn(source, pos.Line, max(pos.End, pos.Start+1)),
+	}
+}
+
+// charColumn converts a zero-based byte offset within a line into a one-based
+// column counted in characters
+func charColumn(source []byte, line, offset int) int {
+	lines := strings.Split(string(source), "\n")
+	if line < 0 || line >= len(lines) || offset > len(lines[line]) {
+		return offset + 1
+	}
+	return utf8.RuneCountInString(lines[line][:offset]) + 1
+}
+
+type jsonLocation struct {
+	Message string `json:"message,omitempty"`
+	File    string `json:"file"`
+	*region
+}
+
+type jsonFrame struct {
+	Function string `json:"function"`
+	jsonLocation
+}
+
+type jsonDiagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	File     string `json:"file"`
+	// region is nil for diagnostics without a position
+	*region
+	Hint    string         `json:"hint,omitempty"`
+	Related []jsonLocation `json:"related"`
+	Trace   []jsonFrame    `json:"trace,omitempty"`
+}
+
+// WriteJSON writes diagnostics as a JSON array with one object per diagnostic
+func WriteJSON(w io.Writer, diags []Diagnostic, sources SourceFunc) error {
+	records := make([]jsonDiagnostic, 0, len(diags))
+	for _, d := range diags {
+		record := jsonDiagnostic{
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+			File:     d.File,
+			Hint:     d.Hint,
+			Related:  []jsonLocation{},
+		}
+		if d.Positioned {
+			record.region = newRegion(d.Pos, sources(d.File))
+		}
+		for _, related := range d.Related {
+			file := related.relativeTo(d)
+			record.Related = append(record.Related, jsonLocation{
+				Message: related.Message,
+				File:    file,
+				region:  newRegion(related.Pos, sources(file)),
+			})
+		}
+		for _, frame := range d.Trace {
+			file := frame.relativeTo(d)
+			record.Trace = append(record.Trace, jsonFrame{
+				Function:     frame.Function,
+				jsonLocation: jsonLocation{File: file, region: newRegion(frame.Pos, sources(file))},
+			})
+		}
+		records = append(records, record)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// relativeTo returns the file of a related location, which defaults to the
+// file of the diagnostic
+func (r Related) relativeTo(d Diagnostic) string {
+	if r.File == "" {
+		return d.File
+	}
+	return r.File
+}
+
+// relativeTo returns the file of a frame, which defaults to the file of the
+// diagnostic for programs run without a module
+func (f Frame) relativeTo(d Diagnostic) string {
+	if f.File == "" {
+		return d.File
+	}
+	return f.File
+}
+
+// The subset of the SARIF 2.1.0 format written by WriteSARIF
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID string `json:"id"`
+	}
+	sarifResult struct {
+		RuleID           string            `json:"ruleId"`
+		Level            string            `json:"level"`
+		Message          sarifMessage      `json:"message"`
+		Locations        []sarifLocation   `json:"locations"`
+		RelatedLocations []sarifLocation   `json:"relatedLocations,omitempty"`
+		Stacks           []sarifStack      `json:"stacks,omitempty"`
+		Properties       map[string]string `json:"properties,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		ID               *int                  `json:"id,omitempty"`
+		Message          *sarifMessage         `json:"message,omitempty"`
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           *region               `json:"region,omitempty"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifStack struct {
+		Frames []sarifFrame `json:"frames"`
+	}
+	sarifFrame struct {
+		Location sarifLocation `json:"location"`
+	}
+)
+
+func newSarifLocation(file string, r *region, message string) sarifLocation {
+	location := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(file)},
+			Region:           r,
+		},
+	}
+	if message != "" {
+		location.Message = &sarifMessage{Text: message}
+	}
+	return location
+}
+
+// WriteSARIF writes diagnostics as a SARIF 2.1.0 log, with each diagnostic's
+// code as its rule
+func WriteSARIF(w io.Writer, diags []Diagnostic, sources SourceFunc) error {
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: "glox", Rules: []sarifRule{}}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	seenRules := map[string]bool{}
+	for _, d := range diags {
+		if !seenRules[d.Code] {
+			seenRules[d.Code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Code})
+		}
+		var r *region
+		if d.Positioned {
+			r = newRegion(d.Pos, sources(d.File))
+		}
+		result := sarifResult{
+			RuleID:    d.Code,
+			Level:     d.Severity,
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{newSarifLocation(d.File, r, "")},
+		}
+		for i, related := range d.Related {
+			file := related.relativeTo(d)
+			location := newSarifLocation(file, newRegion(related.Pos, sources(file)), related.Message)
+			id := i
+			location.ID = &id
+			result.RelatedLocations = append(result.RelatedLocations, location)
+		}
+		if len(d.Trace) > 0 {
+			stack := sarifStack{}
+			for _, frame := range d.Trace {
+				file := frame.relativeTo(d)
+				stack.Frames = append(stack.Frames, sarifFrame{
+					Location: newSarifLocation(file, newRegion(frame.Pos, sources(file)), frame.Function),
+				})
+			}
+			result.Stacks = []sarifStack{stack}
+		}
+		if d.Hint != "" {
+			result.Properties = map[string]string{"hint": d.Hint}
+		}
+		run.Results = append(run.Results, result)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,142 @@
+package glox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runDiagnostics runs the main.lox module in dir and returns the diagnostics
+// for the error it fails with
+func runDiagnostics(t *testing.T, dir string) ([]Diagnostic, SourceFunc) {
+	t.Helper()
+	path := filepath.Join(dir, "main.lox")
+	_, err := runModule(t, path)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	sources := func(file string) []byte {
+		source, _ := os.ReadFile(file)
+		return source
+	}
+	return Diagnostics(err, path), sources
+}
+
+func TestWriteJSON(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox": "import \"lib.lox\";\nfun go() { lib.boom(\"é\"); }\ngo();",
+		"lib.lox":  "fun boom(x) {\n  return \"é\" - x;\n}",
+	})
+	diags, sources := runDiagnostics(t, dir)
+	builder := &strings.Builder{}
+	if err := WriteJSON(builder, diags, sources); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]any
+	if err := json.Unmarshal([]byte(builder.String()), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	expected := map[string]any{
+		"severity":    "error",
+		"code":        ErrorKindType,
+		"message":     "operands of '-' must be numbers, got string and string",
+		"file":        filepath.Join(dir, "lib.lox"),
+		"startLine":   2.0,
+		"startColumn": 14.0,
+		"endLine":     2.0,
+		"endColumn":   15.0,
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, record[key])
+		}
+	}
+	trace, _ := record["trace"].([]any)
+	if len(trace) != 2 {
+		t.Fatalf("Expected 2 frames, got %v", record["trace"])
+	}
+	frame := trace[0].(map[string]any)
+	if frame["function"] != "boom" || frame["file"] != filepath.Join(dir, "main.lox") || frame["startLine"] != 2.0 || frame["startColumn"] != 24.0 {
+		t.Errorf("Expected frame for the call to boom, got %v", frame)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lox": "const limit = 1;\nlimit = 2;\nprint (;",
+	})
+	diags, sources := runDiagnostics(t, dir)
+	builder := &strings.Builder{}
+	if err := WriteSARIF(builder, diags, sources); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           region
+					}
+				}
+				RelatedLocations []struct {
+					Message          struct{ Text string }
+					PhysicalLocation struct{ Region region }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(builder.String()), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected one SARIF 2.1.0 run, got %s", builder)
+	}
+	// The syntax error stops the program before the resolver runs
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != CodeSyntax || results[0].Level != SeverityError {
+		t.Fatalf("Expected one syntax error, got %+v", results)
+	}
+	if r := results[0].Locations[0].PhysicalLocation.Region; r != (region{StartLine: 3, StartColumn: 8, EndLine: 3, EndColumn: 9}) {
+		t.Errorf("Expected error at 3:8, got %+v", r)
+	}
+
+	// Without the syntax error, the resolver reports the constant's declaration
+	// as a related location
+	dir = writeModules(t, map[string]string{
+		"main.lox": "const limit = 1;\nlimit = 2;",
+	})
+	diags, sources = runDiagnostics(t, dir)
+	builder.Reset()
+	if err := WriteSARIF(builder, diags, sources); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(builder.String()), &log); err != nil {
+		t.Fatal(err)
+	}
+	results = log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != CodeResolve || len(results[0].RelatedLocations) != 1 {
+		t.Fatalf("Expected one resolve error with a related location, got %+v", results)
+	}
+	related := results[0].RelatedLocations[0]
+	if related.Message.Text != "constant declared here" || related.PhysicalLocation.Region.StartLine != 1 || related.PhysicalLocation.Region.StartColumn != 7 {
+		t.Errorf("Expected the constant's declaration, got %+v", related)
+	}
+}
@@ -0,0 +1,384 @@
+package glox
+
+import (
+	"errors"
+	"fmt"
+)
+
+// varRef is where the Resolver records how many environments separate a
+// variable use from its declaration. Nodes hold it by pointer, so the record is
+// shared by every copy of the node. A depth of -1 means the variable is global
+// and is looked up by name in the outermost environment.
+type varRef struct {
+	depth int
+}
+
+func newVarRef() *varRef {
+	return &varRef{depth: -1}
+}
+
+type funcType int
+
+const (
+	funcTypeNone funcType = iota
+	funcTypeFunction
+	funcTypeMethod
+	funcTypeInitializer
+)
+
+type classType int
+
+const (
+	classTypeNone classType = iota
+	classTypeClass
+	classTypeSubclass
+)
+
+// Resolver is a static pass run between parsing and execution. It binds each
+// local variable use to the scope that declares it and reports errors that
+// can be found without running the program.
+type Resolver struct {
+	// scopes is the stack of local block scopes. Each maps a name to whether
+	// its initializer has finished resolving. Globals are not tracked.
+	scopes []map[string]bool
+	// consts records the declaration of every constant, with one map per
+	// entry in scopes, after a first map for the globals.
+	consts       []map[string]Token
+	currentFunc  funcType
+	currentClass classType
+	// loopDepth is the number of loops enclosing the current statement within
+	// the current function, used to reject misplaced break and continue.
+	loopDepth int
+	errs      []error
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{consts: []map[string]Token{{}}}
+}
+
+// Resolve resolves a whole program, returning every error it finds
+func (r *Resolver) Resolve(statements []Stmt) error {
+	r.resolveStmts(statements)
+	if len(r.errs) > 0 {
+		return errors.Join(r.errs...)
+	}
+	return nil
+}
+
+// ResolveError is an error found by the Resolver
+type ResolveError struct {
+	Message string
+	Pos     Pos
+	Related []Related
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Message, e.Pos)
+}
+
+// errorf records an error at pos, returning it so that related locations can
+// be added
+func (r *Resolver) errorf(pos Pos, format string, args ...any) *ResolveError {
+	err := &ResolveError{Message: fmt.Sprintf(format, args...), Pos: pos}
+	r.errs = append(r.errs, err)
+	return err
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, map[string]bool{})
+	r.consts = append(r.consts, map[string]Token{})
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	r.consts = r.consts[:len(r.consts)-1]
+}
+
+// declare adds a name to the innermost scope, marked as not yet ready to read
+func (r *Resolver) declare(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope[name.Lexeme]; ok {
+		r.errorf(name.Pos, "variable '%s' is already declared in this scope", name.Lexeme)
+	}
+	scope[name.Lexeme] = false
+}
+
+// define marks a declared name as initialized and ready to read
+func (r *Resolver) define(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1][name.Lexeme] = true
+}
+
+// resolveLocal records the depth of the innermost scope declaring name. Names
+// not found in any scope are left as globals.
+func (r *Resolver) resolveLocal(ref *varRef, name string) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, ok := r.scopes[i][name]; ok {
+			ref.depth = len(r.scopes) - 1 - i
+			return
+		}
+	}
+}
+
+// checkAssignable reports an assignment to name if it resolves to a constant.
+// Globals only resolve to constants declared earlier in the program; the rest
+// are caught when the assignment runs.
+func (r *Resolver) checkAssignable(name Token) {
+	consts := r.consts[0]
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, ok := r.scopes[i][name.Lexeme]; ok {
+			consts = r.consts[i+1]
+			break
+		}
+	}
+	if decl, ok := consts[name.Lexeme]; ok {
+		err := r.errorf(name.Pos, "cannot assign to constant '%s'", name.Lexeme)
+		err.Related = append(err.Related, Related{Message: "constant declared here", Pos: decl.Pos})
+	}
+}
+
+func (r *Resolver) resolveStmts(statements []Stmt) {
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *Resolver) resolveStmt(s Stmt) {
+	switch v := s.(type) {
+	case PrintStmt:
+		r.resolveExpr(v.expr)
+	case ExprStmt:
+		r.resolveExpr(v.expr)
+	case VarDecl:
+		r.declare(v.name)
+		if v.initializer != nil {
+			r.resolveExpr(v.initializer)
+		}
+		r.define(v.name)
+		if v.constant {
+			r.consts[len(r.consts)-1][v.name.Lexeme] = v.name
+		}
+	case ImportStmt:
+		r.declare(v.name)
+		r.define(v.name)
+	case FuncDecl:
+		r.declare(v.name)
+		r.define(v.name)
+		r.resolveFunction(v, funcTypeFunction)
+	case ClassDecl:
+		r.resolveClass(v)
+	case Block:
+		r.beginScope()
+		r.resolveStmts(v.statements)
+		r.endScope()
+	case IfStmt:
+		r.resolveExpr(v.condition)
+		r.resolveStmt(v.thenBranch)
+		if v.elseBranch != nil {
+			r.resolveStmt(v.elseBranch)
+		}
+	case WhileStmt:
+		r.resolveExpr(v.condition)
+		r.loopDepth++
+		r.resolveStmt(v.body)
+		r.loopDepth--
+		if v.increment != nil {
+			r.resolveExpr(v.increment)
+		}
+	case ForInStmt:
+		r.resolveExpr(v.iterable)
+		// The loop variable is bound in its own scope around the body
+		r.beginScope()
+		r.declare(v.name)
+		r.define(v.name)
+		r.loopDepth++
+		r.resolveStmt(v.body)
+		r.loopDepth--
+		r.endScope()
+	case ThrowStmt:
+		r.resolveExpr(v.value)
+	case TryStmt:
+		r.resolveStmt(v.body)
+		if v.catchBody != nil {
+			// The caught value is bound in its own scope around the block
+			r.beginScope()
+			r.declare(*v.catchName)
+			r.define(*v.catchName)
+			r.resolveStmt(*v.catchBody)
+			r.endScope()
+		}
+		if v.finallyBody != nil {
+			r.resolveStmt(*v.finallyBody)
+		}
+	case BreakStmt:
+		if r.loopDepth == 0 {
+			r.errorf(v.keyword.Pos, "cannot use 'break' outside of a loop")
+		}
+	case ContinueStmt:
+		if r.loopDepth == 0 {
+			r.errorf(v.keyword.Pos, "cannot use 'continue' outside of a loop")
+		}
+	case ReturnStmt:
+		if r.currentFunc == funcTypeNone {
+			r.errorf(v.keyword.Pos, "cannot return from top-level code")
+		}
+		if v.value != nil {
+			if r.currentFunc == funcTypeInitializer {
+				r.errorf(v.keyword.Pos, "cannot return a value from an initializer")
+			}
+			r.resolveExpr(v.value)
+		}
+	default:
+		panic(fmt.Errorf("resolver: unknown stmt type %T", s))
+	}
+}
+
+func (r *Resolver) resolveFunction(f FuncDecl, kind funcType) {
+	enclosingFunc, enclosingLoopDepth := r.currentFunc, r.loopDepth
+	r.currentFunc, r.loopDepth = kind, 0
+
+	// Parameters and body share one scope, matching DefinedFunc.Call
+	r.beginScope()
+	seen := map[string]bool{}
+	for _, param := range f.params {
+		if seen[param.Lexeme] {
+			r.errorf(param.Pos, "duplicate parameter '%s'", param.Lexeme)
+			continue
+		}
+		seen[param.Lexeme] = true
+		r.declare(param)
+		r.define(param)
+	}
+	r.resolveStmts(f.body)
+	r.endScope()
+
+	r.currentFunc, r.loopDepth = enclosingFunc, enclosingLoopDepth
+}
+
+func (r *Resolver) resolveClass(c ClassDecl) {
+	enclosingClass := r.currentClass
+	r.currentClass = classTypeClass
+	r.declare(c.name)
+	r.define(c.name)
+
+	if c.superclass != nil {
+		if c.superclass.name.Lexeme == c.name.Lexeme {
+			r.errorf(c.superclass.name.Pos, "a class cannot inherit from itself")
+		}
+		r.currentClass = classTypeSubclass
+		r.resolveExpr(*c.superclass)
+		r.beginScope()
+		r.scopes[len(r.scopes)-1]["super"] = true
+	}
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+	for _, method := range c.methods {
+		kind := funcTypeMethod
+		if method.name.Lexeme == "init" {
+			kind = funcTypeInitializer
+		}
+		r.resolveFunction(method, kind)
+	}
+	r.endScope()
+
+	if c.superclass != nil {
+		r.endScope()
+	}
+	r.currentClass = enclosingClass
+}
+
+func (r *Resolver) resolveExprs(exprs []Expr) {
+	for _, expr := range exprs {
+		r.resolveExpr(expr)
+	}
+}
+
+func (r *Resolver) resolveExpr(e Expr) {
+	switch v := e.(type) {
+	case Literal:
+	case Identifier:
+		if len(r.scopes) > 0 {
+			if ready, ok := r.scopes[len(r.scopes)-1][v.name.Lexeme]; ok && !ready {
+				r.errorf(v.name.Pos, "cannot read local variable '%s' in its own initializer", v.name.Lexeme)
+			}
+		}
+		r.resolveLocal(v.ref, v.name.Lexeme)
+	case Assign:
+		r.resolveExpr(v.val)
+		r.resolveLocal(v.ref, v.name.Lexeme)
+		r.checkAssignable(v.name)
+	case UnaryExpr:
+		r.resolveExpr(v.right)
+	case BinaryExpr:
+		r.resolveExpr(v.left)
+		r.resolveExpr(v.right)
+	case Logical:
+		r.resolveExpr(v.left)
+		r.resolveExpr(v.right)
+	case Conditional:
+		r.resolveExpr(v.condition)
+		r.resolveExpr(v.thenBranch)
+		r.resolveExpr(v.elseBranch)
+	case Grouping:
+		r.resolveExpr(v.expr)
+	case Call:
+		r.resolveExpr(v.callee)
+		r.resolveExprs(v.args)
+	case Get:
+		r.resolveExpr(v.object)
+	case Set:
+		r.resolveExpr(v.val)
+		r.resolveExpr(v.object)
+	case This:
+		if r.currentClass == classTypeNone {
+			r.errorf(v.keyword.Pos, "cannot use 'this' outside of a class")
+			return
+		}
+		r.resolveLocal(v.ref, "this")
+	case CompoundAssign:
+		r.resolveExpr(v.val)
+		r.resolveExpr(v.target)
+		if target, ok := v.target.(Identifier); ok {
+			r.checkAssignable(target.name)
+		}
+	case Update:
+		r.resolveExpr(v.target)
+		if target, ok := v.target.(Identifier); ok {
+			r.checkAssignable(target.name)
+		}
+	case Interpolation:
+		r.resolveExprs(v.parts)
+	case ListExpr:
+		r.resolveExprs(v.elements)
+	case MapExpr:
+		for i := range v.keys {
+			r.resolveExpr(v.keys[i])
+			r.resolveExpr(v.values[i])
+		}
+	case GetIndex:
+		r.resolveExpr(v.object)
+		r.resolveExpr(v.index)
+	case SetIndex:
+		r.resolveExpr(v.val)
+		r.resolveExpr(v.object)
+		r.resolveExpr(v.index)
+	case FuncExpr:
+		r.resolveFunction(v.decl(), funcTypeFunction)
+	case Super:
+		switch r.currentClass {
+		case classTypeNone:
+			r.errorf(v.keyword.Pos, "cannot use 'super' outside of a class")
+		case classTypeClass:
+			r.errorf(v.keyword.Pos, "cannot use 'super' in a class with no superclass")
+		}
+		r.resolveLocal(v.ref, "super")
+	default:
+		panic(fmt.Errorf("resolver: unknown expr type %T", e))
+	}
+}
@@ -0,0 +1,53 @@
+package glox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolverBindsLexically(t *testing.T) {
+	env := runSource(t, `
+var a = "global";
+var first;
+var second;
+{
+  fun showA() {
+    return a;
+  }
+  first = showA();
+  var a = "block";
+  second = showA();
+}
+`)
+	expectGlobal(t, env, "first", "global")
+	expectGlobal(t, env, "second", "global")
+}
+
+func TestResolverErrors(t *testing.T) {
+	cases := map[string]string{
+		"{ var a = a; }":                         "own initializer",
+		"{ var a; var a; }":                      "already declared",
+		"fun f(a, a) {}":                         "duplicate parameter 'a'",
+		"return 1;":                              "top-level code",
+		"class A { init() { return 1; } }":       "from an initializer",
+		"print this;":                            "'this' outside of a class",
+		"class A { f() { super.f(); } }":         "no superclass",
+		"class A < A {}":                         "inherit from itself",
+		"break;":                                 "'break' outside of a loop",
+		"while (true) { fun f() { continue; } }": "'continue' outside of a loop",
+		"const a = 1; a = 2;":                    "cannot assign to constant 'a' (line 1 [13:14])",
+		"{ const a = 1; fun f() { a += 1; } }":   "cannot assign to constant 'a'",
+		"{ const a = 1; { a++; } }":              "cannot assign to constant 'a'",
+	}
+	for source, expected := range cases {
+		tokens, err := NewScanner([]byte(source)).ScanTokens()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts, _ := NewParser(tokens).Program()
+		err = NewResolver().Resolve(stmts)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q for %q, got %v", expected, source, err)
+		}
+	}
+}
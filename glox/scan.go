@@ -3,6 +3,9 @@ package glox
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type Scanner struct {
@@ -11,8 +14,16 @@ type Scanner struct {
 	// visitedLinesLen is the number of characters in lines already visited. This
 	// can be subtracted from current to get the position within a line.
 	visitedLinesLen int
-	source          []byte
-	tokens          []Token
+	// startLine and startLineLen are the values of line and visitedLinesLen
+	// when the current token started, so that tokens spanning several lines
+	// are positioned at their first character.
+	startLine    int
+	startLineLen int
+	source       []byte
+	tokens       []Token
+	// interpolations holds a brace depth for each ${ currently open, so that
+	// the } closing the interpolated expression resumes scanning the string.
+	interpolations []int
 }
 
 func NewScanner(source []byte) *Scanner {
@@ -23,6 +34,24 @@ func NewScanner(source []byte) *Scanner {
 	return s
 }
 
+// ScanError is a malformed token found by the Scanner
+type ScanError struct {
+	Message string
+	Pos     Pos
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%s (line %d col %d)", e.Message, e.Pos.Line+1, e.Pos.Start+1)
+}
+
+// errorAt creates a ScanError at a zero-based line and one-based column
+func (s *Scanner) errorAt(line, col int, format string, args ...any) *ScanError {
+	return &ScanError{
+		Message: fmt.Sprintf(format, args...),
+		Pos:     Pos{Line: line, Start: col - 1, End: col},
+	}
+}
+
 func (s *Scanner) ScanTokens() ([]Token, error) {
 	errs := []error{}
 	for int(s.current) < len(s.source) {
@@ -31,12 +60,15 @@ func (s *Scanner) ScanTokens() ([]Token, error) {
 			errs = append(errs, err)
 		}
 	}
+	if len(s.interpolations) > 0 {
+		errs = append(errs, s.errorAt(s.line, s.current-s.visitedLinesLen+1, "unterminated string interpolation"))
+	}
 	s.tokens = append(s.tokens, Token{
 		Type:    TokenTypeEOF,
 		Lexeme:  "",
 		Literal: nil,
 		Pos: Pos{
-			Line:  s.line + 1,
+			Line:  s.line,
 			Start: 0,
 			End:   0,
 		},
@@ -49,6 +81,7 @@ func (s *Scanner) ScanTokens() ([]Token, error) {
 
 func (s *Scanner) scanToken() error {
 	start := s.current
+	s.startLine, s.startLineLen = s.line, s.visitedLinesLen
 	c := s.source[s.current]
 	s.current++
 	var peek byte
@@ -65,8 +98,9 @@ func (s *Scanner) scanToken() error {
 	// Ignore whitespace
 	case '/':
 		if peek == '/' {
-			// Consume characters until end of line
-			for s.source[s.current] != '\n' && s.current < len(s.source) {
+			// Consume characters until end of line, leaving the newline to
+			// be counted by the newline case
+			for s.current < len(s.source) && s.source[s.current] != '\n' {
 				s.current++
 			}
 			// Strip off double slash and leading space
@@ -75,8 +109,11 @@ func (s *Scanner) scanToken() error {
 				comment = comment[1:]
 			}
 			s.addLiteralToken(start, TokenTypeComment, comment)
-			s.line++
-			s.visitedLinesLen = s.current
+		} else if peek == '*' {
+			return s.scanBlockComment(start)
+		} else if peek == '=' {
+			s.current++
+			s.addToken(start, TokenTypeSlashEqual)
 		} else {
 			s.addToken(start, TokenTypeSlash)
 		}
@@ -87,23 +124,85 @@ func (s *Scanner) scanToken() error {
 	case ')':
 		s.addToken(start, TokenTypeRightParen)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		s.addToken(start, TokenTypeLeftBrace)
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				// End of an interpolated expression: continue the string
+				s.interpolations = s.interpolations[:n-1]
+				return s.scanString(start)
+			}
+			s.interpolations[n-1]--
+		}
 		s.addToken(start, TokenTypeRightBrace)
+	case '[':
+		s.addToken(start, TokenTypeLeftBracket)
+	case ']':
+		s.addToken(start, TokenTypeRightBracket)
 	case ',':
 		s.addToken(start, TokenTypeComma)
+	case ':':
+		s.addToken(start, TokenTypeColon)
 	case '.':
 		s.addToken(start, TokenTypeDot)
-	case '-':
-		s.addToken(start, TokenTypeMinus)
-	case '+':
-		s.addToken(start, TokenTypePlus)
 	case ';':
 		s.addToken(start, TokenTypeSemicolon)
-	case '*':
-		s.addToken(start, TokenTypeStar)
 
 		// 1-2 character tokens
+	case '-':
+		if peek == '-' {
+			s.current++
+			s.addToken(start, TokenTypeMinusMinus)
+		} else if peek == '=' {
+			s.current++
+			s.addToken(start, TokenTypeMinusEqual)
+		} else {
+			s.addToken(start, TokenTypeMinus)
+		}
+	case '+':
+		if peek == '+' {
+			s.current++
+			s.addToken(start, TokenTypePlusPlus)
+		} else if peek == '=' {
+			s.current++
+			s.addToken(start, TokenTypePlusEqual)
+		} else {
+			s.addToken(start, TokenTypePlus)
+		}
+	case '%':
+		if peek == '=' {
+			s.current++
+			s.addToken(start, TokenTypePercentEqual)
+		} else {
+			s.addToken(start, TokenTypePercent)
+		}
+	case '*':
+		if peek == '*' {
+			s.current++
+			s.addToken(start, TokenTypeStarStar)
+		} else if peek == '=' {
+			s.current++
+			s.addToken(start, TokenTypeStarEqual)
+		} else {
+			s.addToken(start, TokenTypeStar)
+		}
+	case '?':
+		if peek == '?' {
+			s.current++
+			s.addToken(start, TokenTypeQuestionQuestion)
+		} else {
+			s.addToken(start, TokenTypeQuestion)
+		}
+	case '~':
+		// Floor division is ~/ because // starts a comment
+		if peek != '/' {
+			return s.errorAt(s.line, s.current-s.visitedLinesLen, "unexpected character: %c", c)
+		}
+		s.current++
+		s.addToken(start, TokenTypeTildeSlash)
 	case '!':
 		if peek == '=' {
 			s.current++
@@ -135,69 +234,255 @@ func (s *Scanner) scanToken() error {
 
 		// String handling
 	case '"':
-		for s.source[s.current] != '"' && s.current < len(s.source) {
-			if s.source[s.current] == '\n' {
-				s.line++
-				s.visitedLinesLen = s.current
+		return s.scanString(start)
+
+	// Numbers
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return s.scanNumber(start)
+
+	default:
+		if isAlpha(c) {
+			for s.current < len(s.source) && isAlphaNumeric(s.source[s.current]) {
+				s.current++
+			}
+			s.addIdentifier(start)
+		} else {
+			return s.errorAt(s.line, s.current-s.visitedLinesLen, "unexpected character: %c", c)
+		}
+	}
+	return nil
+}
+
+// scanNumber scans a number literal and parses it into a float64 literal.
+// Numbers may be decimal with an optional fraction and exponent, or integers
+// prefixed with 0x, 0b or 0o. Digits may be separated by single underscores.
+func (s *Scanner) scanNumber(start int) error {
+	malformed := func(reason string) error {
+		// Consume the rest of the literal so that it is only reported once
+		for s.current < len(s.source) && isAlphaNumeric(s.source[s.current]) {
+			s.current++
+		}
+		return s.errorAt(s.line, start-s.visitedLinesLen+1, "malformed number %s: %s", s.source[start:s.current], reason)
+	}
+
+	if s.source[start] == '0' && s.current < len(s.source) {
+		base := 0
+		var isValid func(byte) bool
+		switch s.source[s.current] {
+		case 'x', 'X':
+			base, isValid = 16, isHexDigit
+		case 'b', 'B':
+			base, isValid = 2, isBinaryDigit
+		case 'o', 'O':
+			base, isValid = 8, isOctalDigit
+		}
+		if base != 0 {
+			s.current++
+			digits, err := s.scanDigits(isValid)
+			if err != nil {
+				return malformed(err.Error())
+			}
+			if digits == "" {
+				return malformed("expected digits after prefix")
+			}
+			if s.current < len(s.source) && isAlphaNumeric(s.source[s.current]) {
+				return malformed(fmt.Sprintf("invalid digit '%c' for base %d", s.source[s.current], base))
 			}
+			n, err := strconv.ParseUint(digits, base, 64)
+			if err != nil {
+				return malformed("value out of range")
+			}
+			s.addLiteralToken(start, TokenTypeNumber, float64(n))
+			return nil
+		}
+	}
+
+	s.current = start
+	number, err := s.scanDigits(isDigit)
+	if err != nil {
+		return malformed(err.Error())
+	}
+	if s.current+1 < len(s.source) && s.source[s.current] == '.' && isDigit(s.source[s.current+1]) {
+		s.current++
+		fraction, err := s.scanDigits(isDigit)
+		if err != nil {
+			return malformed(err.Error())
+		}
+		number += "." + fraction
+	}
+	if s.current < len(s.source) && (s.source[s.current] == 'e' || s.source[s.current] == 'E') {
+		s.current++
+		number += "e"
+		if s.current < len(s.source) && (s.source[s.current] == '+' || s.source[s.current] == '-') {
+			number += string(s.source[s.current])
 			s.current++
 		}
-		if s.current >= len(s.source) {
-			return fmt.Errorf("unterminated string (line %d pos %d)", s.line+1, s.current-s.visitedLinesLen)
+		exponent, err := s.scanDigits(isDigit)
+		if err != nil {
+			return malformed(err.Error())
+		}
+		if exponent == "" {
+			return malformed("expected exponent digits")
 		}
+		number += exponent
+	}
+	if s.current < len(s.source) && isAlpha(s.source[s.current]) {
+		return malformed(fmt.Sprintf("unexpected '%c'", s.source[s.current]))
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return malformed("value out of range")
+	}
+	s.addLiteralToken(start, TokenTypeNumber, n)
+	return nil
+}
+
+// scanDigits consumes a run of digits accepted by isValid and the underscores
+// separating them, returning the digits without underscores
+func (s *Scanner) scanDigits(isValid func(byte) bool) (string, error) {
+	digitsStart := s.current
+	for s.current < len(s.source) && (isValid(s.source[s.current]) || s.source[s.current] == '_') {
 		s.current++
-		s.addLiteralToken(start, TokenTypeString, string(s.source[start+1:s.current-1]))
+	}
+	digits := string(s.source[digitsStart:s.current])
+	if strings.HasPrefix(digits, "_") || strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+		return "", errors.New("digit separators must be single underscores between digits")
+	}
+	return strings.ReplaceAll(digits, "_", ""), nil
+}
 
-	// Numbers
-	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		for s.current < len(s.source) && isDigit(s.source[s.current]) {
+// scanBlockComment scans a /* */ comment, which may span lines and contain
+// nested block comments
+func (s *Scanner) scanBlockComment(start int) error {
+	s.current++
+	depth := 1
+	for s.current < len(s.source) && depth > 0 {
+		c := s.source[s.current]
+		var next byte
+		if s.current+1 < len(s.source) {
+			next = s.source[s.current+1]
+		}
+		switch {
+		case c == '/' && next == '*':
+			depth++
+			s.current += 2
+		case c == '*' && next == '/':
+			depth--
+			s.current += 2
+		case c == '\n':
+			s.current++
+			s.line++
+			s.visitedLinesLen = s.current
+		default:
 			s.current++
 		}
-		if s.source[s.current] == '.' && isDigit(s.source[s.current+1]) {
-			// Advance to end of non-integer number
+	}
+	if depth > 0 {
+		return s.errorAt(s.startLine, start-s.startLineLen+1, "unterminated block comment")
+	}
+	comment := strings.TrimSpace(string(s.source[start+2 : s.current-2]))
+	s.addLiteralToken(start, TokenTypeComment, comment)
+	return nil
+}
+
+// scanString scans the rest of a string literal, after its opening quote or
+// after the } that closes an interpolated expression. Escape sequences are
+// decoded into the token's literal. The string ends at its closing quote, or at
+// ${ if an expression is interpolated next.
+func (s *Scanner) scanString(start int) error {
+	builder := &strings.Builder{}
+	errs := []error{}
+	for s.current < len(s.source) {
+		c := s.source[s.current]
+		switch {
+		case c == '"':
 			s.current++
-			for s.current < len(s.source) && isDigit(s.source[s.current]) {
-				s.current++
+			s.addLiteralToken(start, TokenTypeString, builder.String())
+			return errors.Join(errs...)
+		case c == '$' && s.current+1 < len(s.source) && s.source[s.current+1] == '{':
+			s.current += 2
+			s.addLiteralToken(start, TokenTypeInterpolation, builder.String())
+			s.interpolations = append(s.interpolations, 0)
+			return errors.Join(errs...)
+		case c == '\\':
+			if err := s.scanEscape(builder); err != nil {
+				errs = append(errs, err)
 			}
+			continue
+		case c == '\n':
+			s.line++
+			s.visitedLinesLen = s.current + 1
 		}
-		s.addLiteralToken(start, TokenTypeNumber, string(s.source[start:s.current]))
+		builder.WriteByte(c)
+		s.current++
+	}
+	errs = append(errs, s.errorAt(s.startLine, start-s.startLineLen+1, "unterminated string"))
+	return errors.Join(errs...)
+}
 
-	default:
-		if isAlpha(c) {
-			for s.current < len(s.source) && isAlphaNumeric(s.source[s.current]) {
-				s.current++
-			}
-			s.addIdentifier(start)
-		} else {
-			return fmt.Errorf("unexpected character: %c (line %d col %d)", c, s.line+1, s.current-s.visitedLinesLen)
+// scanEscape decodes the escape sequence whose backslash is at s.current
+func (s *Scanner) scanEscape(builder *strings.Builder) error {
+	col := s.current - s.visitedLinesLen + 1
+	s.current++
+	if s.current >= len(s.source) {
+		return s.errorAt(s.line, col, "unterminated escape sequence")
+	}
+	c := s.source[s.current]
+	s.current++
+	switch c {
+	case 'n':
+		builder.WriteByte('\n')
+	case 't':
+		builder.WriteByte('\t')
+	case 'r':
+		builder.WriteByte('\r')
+	case '0':
+		builder.WriteByte(0)
+	case '"', '\\', '$':
+		builder.WriteByte(c)
+	case 'u':
+		// \u{X} through \u{XXXXXX}
+		if s.current >= len(s.source) || s.source[s.current] != '{' {
+			return s.errorAt(s.line, col, "expected '{' after \\u")
+		}
+		digitsStart := s.current + 1
+		s.current = digitsStart
+		for s.current < len(s.source) && isHexDigit(s.source[s.current]) {
+			s.current++
+		}
+		digits := string(s.source[digitsStart:s.current])
+		if s.current >= len(s.source) || s.source[s.current] != '}' {
+			return s.errorAt(s.line, col, "unterminated unicode escape \\u{%s", digits)
 		}
+		s.current++
+		n, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(n)) {
+			return s.errorAt(s.line, col, "invalid unicode escape \\u{%s}", digits)
+		}
+		builder.WriteRune(rune(n))
+	default:
+		return s.errorAt(s.line, col, "invalid escape sequence \\%c", c)
 	}
 	return nil
 }
 
 // addToken appends a non-literal token to the token list
 func (s *Scanner) addToken(start int, tokenType TokenType) {
-	s.tokens = append(s.tokens, Token{
-		Type:    tokenType,
-		Lexeme:  string(s.source[start:s.current]),
-		Literal: nil,
-		Pos: Pos{
-			Line:  s.line,
-			Start: start - s.visitedLinesLen,
-			End:   s.current - s.visitedLinesLen,
-		},
-	})
+	s.addLiteralToken(start, tokenType, nil)
 }
 
+// addLiteralToken appends a token to the token list. Its position is on the
+// line where the token started; the end of a token spanning several lines is
+// counted from the start of that line too.
 func (s *Scanner) addLiteralToken(start int, tokenType TokenType, literal interface{}) {
 	s.tokens = append(s.tokens, Token{
 		Type:    tokenType,
 		Lexeme:  string(s.source[start:s.current]),
 		Literal: literal,
 		Pos: Pos{
-			Line:  s.line,
-			Start: start - s.visitedLinesLen,
-			End:   s.current - s.visitedLinesLen,
+			Line:  s.startLine,
+			Start: start - s.startLineLen,
+			End:   s.current - s.startLineLen,
 		},
 	})
 }
@@ -60,3 +60,134 @@ var x fun longIdentifier
 		}
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	source := []byte(`"tab\tquote\"slash\\dollar\$nl\n" "\u{1F600}\u{e9}"`)
+	tokens, err := NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"tab\tquote\"slash\\dollar$nl\n", "\U0001F600é"}
+	for i, literal := range expected {
+		if tokens[i].Literal != literal {
+			t.Errorf("Expected literal %q, got %q", literal, tokens[i].Literal)
+		}
+	}
+
+	for _, bad := range []string{`"\q"`, `"\u{110000}"`, `"\u12"`, `"\u{12"`} {
+		if _, err := NewScanner([]byte(bad)).ScanTokens(); err == nil {
+			t.Errorf("Expected an error scanning %s", bad)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	source := []byte(`"a ${b} c ${ {"k": "${d}"}["k"] }!"`)
+	tokens, err := NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTypes := []TokenType{
+		TokenTypeInterpolation,
+		TokenTypeIdentifier,
+		TokenTypeInterpolation,
+		TokenTypeLeftBrace,
+		TokenTypeString,
+		TokenTypeColon,
+		TokenTypeInterpolation,
+		TokenTypeIdentifier,
+		TokenTypeString,
+		TokenTypeRightBrace,
+		TokenTypeLeftBracket,
+		TokenTypeString,
+		TokenTypeRightBracket,
+		TokenTypeString,
+		TokenTypeEOF,
+	}
+	if len(tokens) != len(expectedTypes) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(expectedTypes), len(tokens), tokens)
+	}
+	for i, token := range tokens {
+		if token.Type != expectedTypes[i] {
+			t.Errorf("Expected token type %v, got %v", expectedTypes[i], token.Type)
+		}
+	}
+	if tokens[13].Literal != "!" {
+		t.Errorf("Expected final string part \"!\", got %q", tokens[13].Literal)
+	}
+
+	if _, err := NewScanner([]byte(`"a ${b`)).ScanTokens(); err == nil {
+		t.Error("Expected an error for an unterminated interpolation")
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	source := []byte(`a /* one
+  /* nested
+  */ still comment */ b // line
+c /* unterminated`)
+	tokens, err := NewScanner(source).ScanTokens()
+	if err == nil {
+		t.Error("Expected an error for an unterminated block comment")
+	}
+
+	tokens, err = NewScanner(source[:len(source)-len("/* unterminated")]).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTypes := []TokenType{
+		TokenTypeIdentifier,
+		TokenTypeComment,
+		TokenTypeIdentifier,
+		TokenTypeComment,
+		TokenTypeIdentifier,
+		TokenTypeEOF,
+	}
+	expectedLines := []int{0, 0, 2, 2, 3}
+	for i, token := range tokens {
+		if token.Type != expectedTypes[i] {
+			t.Errorf("Expected token type %v, got %v", expectedTypes[i], token.Type)
+		}
+		if i < len(expectedLines) && token.Pos.Line != expectedLines[i] {
+			t.Errorf("Expected %s on line %d, got %d", token.Lexeme, expectedLines[i], token.Pos.Line)
+		}
+	}
+	if tokens[1].Literal != "one\n  /* nested\n  */ still comment" {
+		t.Errorf("Unexpected comment literal %q", tokens[1].Literal)
+	}
+	if pos := tokens[2].Pos; pos.Start != 22 || pos.End != 23 {
+		t.Errorf("Expected b at [22:23], got %s", pos)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	valid := map[string]float64{
+		"123":       123,
+		"12.5":      12.5,
+		"0xFF":      255,
+		"0Xff":      255,
+		"0b1010":    10,
+		"0o17":      15,
+		"1e9":       1e9,
+		"2.5e-3":    2.5e-3,
+		"1E+2":      100,
+		"1_000_000": 1000000,
+		"0.000_1":   0.0001,
+	}
+	for source, expected := range valid {
+		tokens, err := NewScanner([]byte(source)).ScanTokens()
+		if err != nil {
+			t.Errorf("Unexpected error scanning %s: %s", source, err)
+			continue
+		}
+		if tokens[0].Literal != expected || tokens[0].Lexeme != source {
+			t.Errorf("Expected %s to scan as %v, got %v", source, expected, tokens[0])
+		}
+	}
+
+	for _, source := range []string{"0x", "1e", "1e+", "1__0", "1_", "0x_FF", "0b102", "0o8", "12abc"} {
+		if _, err := NewScanner([]byte(source)).ScanTokens(); err == nil {
+			t.Errorf("Expected an error scanning %s", source)
+		}
+	}
+}
@@ -16,6 +16,9 @@ func StmtToString(s Stmt) string {
 	case ExprStmt:
 		return parenthesize("expr", v.expr)
 	case VarDecl:
+		if v.constant {
+			return parenthesize("const "+v.name.Lexeme, v.initializer)
+		}
 		return parenthesize("var "+v.name.Lexeme, v.initializer)
 	case Block:
 		stmtStrs := StmtsToStrings(v.statements)
@@ -27,13 +30,48 @@ func StmtToString(s Stmt) string {
 				StmtToString(v.elseBranch),
 		)
 	case WhileStmt:
-		return parenthesize(
-			parenthesize("while", v.condition) + "\n\t" +
-				StmtToString(v.body),
-		)
+		condition := parenthesize("while", v.condition)
+		if v.increment != nil {
+			condition = parenthesize("while", v.condition, v.increment)
+		}
+		return parenthesize(condition + "\n\t" + StmtToString(v.body))
+	case ForInStmt:
+		return parenthesize(parenthesize("for "+v.name.Lexeme+" in", v.iterable) + "\n\t" + StmtToString(v.body))
+	case ImportStmt:
+		return "(import " + v.path.Lexeme + " as " + v.name.Lexeme + ")"
+	case ThrowStmt:
+		return parenthesize("throw", v.value)
+	case TryStmt:
+		str := "(try " + StmtToString(v.body)
+		if v.catchBody != nil {
+			str += "\n(catch " + v.catchName.Lexeme + " " + StmtToString(*v.catchBody) + ")"
+		}
+		if v.finallyBody != nil {
+			str += "\n(finally " + StmtToString(*v.finallyBody) + ")"
+		}
+		return str + ")"
+	case BreakStmt:
+		return "(break)"
+	case ContinueStmt:
+		return "(continue)"
 	case FuncDecl:
 		stmtStrs := StmtsToStrings(v.body)
 		return parenthesize("fun " + v.name.Lexeme + "\n" + strings.Join(stmtStrs, "\n"))
+	case ClassDecl:
+		methodStrs := make([]string, 0, len(v.methods))
+		for _, method := range v.methods {
+			methodStrs = append(methodStrs, StmtToString(method))
+		}
+		name := v.name.Lexeme
+		if v.superclass != nil {
+			name += " < " + v.superclass.name.Lexeme
+		}
+		return parenthesize("class " + name + "\n" + strings.Join(methodStrs, "\n"))
+	case ReturnStmt:
+		if v.value == nil {
+			return "(return)"
+		}
+		return parenthesize("return", v.value)
 	}
 	return fmt.Sprintf("unknown stmt type: %v", s)
 }
@@ -51,7 +89,7 @@ type PrintStmt struct {
 }
 
 func (p PrintStmt) Execute(env *Environment) {
-	fmt.Println(p.expr.Evaluate(env))
+	fmt.Println(stringify(p.expr.Evaluate(env)))
 }
 
 type ExprStmt struct {
@@ -65,6 +103,9 @@ func (e ExprStmt) Execute(env *Environment) {
 type VarDecl struct {
 	name        Token
 	initializer Expr
+	// constant is set for const declarations, whose binding cannot be
+	// assigned after it is initialized
+	constant bool
 }
 
 func (e VarDecl) Execute(env *Environment) {
@@ -72,8 +113,14 @@ func (e VarDecl) Execute(env *Environment) {
 	if e.initializer != nil {
 		v = e.initializer.Evaluate(env)
 	}
-	if err := env.Declare(e.name.Lexeme, v); err != nil {
-		panic(err)
+	var err error
+	if e.constant {
+		err = env.DeclareConst(e.name.Lexeme, v, e.name.Pos)
+	} else {
+		err = env.Declare(e.name.Lexeme, v)
+	}
+	if err != nil {
+		panic(atToken(err, e.name))
 	}
 }
 
@@ -84,8 +131,48 @@ type FuncDecl struct {
 }
 
 func (f FuncDecl) Execute(env *Environment) {
-	function := DefinedFunc{decl: f}
-	env.Declare(f.name.Lexeme, function)
+	function := &DefinedFunc{decl: f, closure: env}
+	if err := env.Declare(f.name.Lexeme, function); err != nil {
+		panic(atToken(err, f.name))
+	}
+}
+
+type ClassDecl struct {
+	name       Token
+	superclass *Identifier
+	methods    []FuncDecl
+}
+
+func (c ClassDecl) Execute(env *Environment) {
+	var superclass *LoxClass
+	methodEnv := env
+	if c.superclass != nil {
+		v := c.superclass.Evaluate(env)
+		class, ok := v.(*LoxClass)
+		if !ok {
+			panic(runtimeError(c.superclass.Pos(), ErrorKindType, "superclass of %s must be a class, got %s", c.name.Lexeme, typeName(v)))
+		}
+		superclass = class
+		// Methods close over an extra environment that binds super
+		methodEnv = NewEnvironment(env)
+		methodEnv.Declare("super", superclass)
+	}
+
+	class := &LoxClass{
+		name:       c.name.Lexeme,
+		superclass: superclass,
+		methods:    map[string]*DefinedFunc{},
+	}
+	for _, method := range c.methods {
+		class.methods[method.name.Lexeme] = &DefinedFunc{
+			decl:          method,
+			closure:       methodEnv,
+			isInitializer: method.name.Lexeme == "init",
+		}
+	}
+	if err := env.Declare(c.name.Lexeme, class); err != nil {
+		panic(atToken(err, c.name))
+	}
 }
 
 type Block struct {
@@ -117,10 +204,182 @@ func (s IfStmt) Execute(env *Environment) {
 type WhileStmt struct {
 	condition Expr
 	body      Stmt
+	// increment is the optional third clause of a desugared for loop, evaluated
+	// after every iteration of the body, including ones ended by continue.
+	increment Expr
 }
 
 func (s WhileStmt) Execute(env *Environment) {
 	for isTruthy(s.condition.Evaluate(env)) {
+		if runLoopBody(s.body, env) {
+			break
+		}
+		if s.increment != nil {
+			s.increment.Evaluate(env)
+		}
+	}
+}
+
+// runLoopBody executes a loop body once, reporting whether it was ended by a
+// break. A continue only ends the body early.
+func runLoopBody(body Stmt, env *Environment) (broke bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case breakLoop:
+				broke = true
+			case continueLoop:
+			default:
+				panic(r)
+			}
+		}
+	}()
+	body.Execute(env)
+	return false
+}
+
+// ForInStmt runs its body once for each value of an iterable. Every iteration
+// declares the loop variable in a new environment, so closures created by the
+// body capture that iteration's value.
+type ForInStmt struct {
+	keyword  Token
+	name     Token
+	iterable Expr
+	body     Stmt
+}
+
+func (s ForInStmt) Execute(env *Environment) {
+	next := iterate(env, s.iterable.Evaluate(env), s.iterable.Pos())
+	for {
+		v, ok := next()
+		if !ok {
+			return
+		}
+		loopEnv := NewEnvironment(env)
+		loopEnv.Declare(s.name.Lexeme, v)
+		if runLoopBody(s.body, loopEnv) {
+			return
+		}
+	}
+}
+
+type ReturnStmt struct {
+	keyword Token
+	value   Expr
+}
+
+// returnValue is panicked by a ReturnStmt to unwind the Go stack back to the
+// DefinedFunc call that is returning, carrying the returned value with it.
+type returnValue struct {
+	value any
+}
+
+func (s ReturnStmt) Execute(env *Environment) {
+	var v any
+	if s.value != nil {
+		v = s.value.Evaluate(env)
+	}
+	panic(returnValue{value: v})
+}
+
+type BreakStmt struct {
+	keyword Token
+}
+
+// breakLoop is panicked by a BreakStmt to unwind to the innermost loop
+type breakLoop struct{}
+
+func (s BreakStmt) Execute(env *Environment) {
+	panic(breakLoop{})
+}
+
+type ContinueStmt struct {
+	keyword Token
+}
+
+// continueLoop is panicked by a ContinueStmt to unwind to the innermost loop
+type continueLoop struct{}
+
+func (s ContinueStmt) Execute(env *Environment) {
+	panic(continueLoop{})
+}
+
+type ThrowStmt struct {
+	keyword Token
+	value   Expr
+}
+
+func (s ThrowStmt) Execute(env *Environment) {
+	v := s.value.Evaluate(env)
+	// Rethrowing a caught error value keeps its original kind and position
+	if rerr, ok := v.(*RuntimeError); ok {
+		panic(rerr)
+	}
+	panic(thrownValue{value: v, pos: s.keyword.Pos})
+}
+
+// TryStmt runs its body, handing any thrown value or runtime error to the
+// catch clause. The finally clause runs however control leaves the statement,
+// including by return, break or an uncaught throw.
+type TryStmt struct {
+	keyword     Token
+	body        Block
+	catchName   *Token
+	catchBody   *Block
+	finallyBody *Block
+}
+
+func (s TryStmt) Execute(env *Environment) {
+	if s.finallyBody != nil {
+		// A panic from the finally body, such as a return, replaces whatever
+		// was unwinding through it
+		defer s.finallyBody.Execute(env)
+	}
+	if s.catchBody == nil {
 		s.body.Execute(env)
+		return
+	}
+	if caught, ok := s.runBody(env); ok {
+		catchEnv := NewEnvironment(env)
+		catchEnv.Declare(s.catchName.Lexeme, caught)
+		s.catchBody.Execute(catchEnv)
+	}
+}
+
+// runBody executes the try body, returning the value it threw, if any
+func (s TryStmt) runBody(env *Environment) (caught any, threw bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, ok := caughtValue(r)
+			if !ok {
+				panic(r)
+			}
+			caught, threw = v, true
+		}
+	}()
+	s.body.Execute(env)
+	return nil, false
+}
+
+type ImportStmt struct {
+	keyword Token
+	path    Token
+	// name is the variable the module is bound to, either given with as or
+	// derived from the path
+	name Token
+}
+
+func (s ImportStmt) Execute(env *Environment) {
+	importer := moduleOf(env)
+	module, err := importer.loader.load(importer.resolve(s.path.Literal.(string)))
+	if merr, ok := err.(*ModuleError); ok {
+		merr.Related = append(merr.Related, Related{Message: "imported here", File: importer.path, Pos: s.path.Pos})
+		panic(merr)
+	}
+	if err != nil {
+		panic(atToken(err, s.path))
+	}
+	if err := env.Declare(s.name.Lexeme, module); err != nil {
+		panic(atToken(err, s.name))
 	}
 }
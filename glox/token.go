@@ -12,13 +12,17 @@ const (
 	TokenTypeRightParen
 	TokenTypeLeftBrace
 	TokenTypeRightBrace
+	TokenTypeLeftBracket
+	TokenTypeRightBracket
 	TokenTypeComma
+	TokenTypeColon
 	TokenTypeDot
 	TokenTypeMinus
 	TokenTypePlus
 	TokenTypeSemicolon
 	TokenTypeSlash
 	TokenTypeStar
+	TokenTypePercent
 
 	// One or two character tokens
 	TokenTypeBang
@@ -29,28 +33,52 @@ const (
 	TokenTypeGreaterEqual
 	TokenTypeLess
 	TokenTypeLessEqual
+	TokenTypeStarStar
+	TokenTypeTildeSlash
+	TokenTypePlusEqual
+	TokenTypeMinusEqual
+	TokenTypeStarEqual
+	TokenTypeSlashEqual
+	TokenTypePercentEqual
+	TokenTypePlusPlus
+	TokenTypeMinusMinus
+	TokenTypeQuestion
+	TokenTypeQuestionQuestion
 
 	// Literals
 	TokenTypeIdentifier
 	TokenTypeString
+	// TokenTypeInterpolation is a string part ending in ${. It is followed by
+	// the tokens of the interpolated expression, then by another interpolation
+	// or the string token that finishes the string.
+	TokenTypeInterpolation
 	TokenTypeNumber
 	TokenTypeComment
 
 	// Keywords
 	TokenTypeAnd
+	TokenTypeBreak
+	TokenTypeCatch
 	TokenTypeClass
+	TokenTypeConst
+	TokenTypeContinue
 	TokenTypeElse
 	TokenTypeFalse
+	TokenTypeFinally
 	TokenTypeFun
 	TokenTypeFor
 	TokenTypeIf
+	TokenTypeImport
+	TokenTypeIn
 	TokenTypeNil
 	TokenTypeOr
 	TokenTypePrint // TODO: this should be a stdlib function call
 	TokenTypeReturn
 	TokenTypeSuper
 	TokenTypeThis
+	TokenTypeThrow
 	TokenTypeTrue
+	TokenTypeTry
 	TokenTypeVar
 	TokenTypeWhile
 
@@ -59,66 +87,100 @@ const (
 
 var (
 	ReservedKeywords = map[string]TokenType{
-		"and":    TokenTypeAnd,
-		"class":  TokenTypeClass,
-		"else":   TokenTypeElse,
-		"false":  TokenTypeFalse,
-		"fun":    TokenTypeFun,
-		"for":    TokenTypeFor,
-		"if":     TokenTypeIf,
-		"nil":    TokenTypeNil,
-		"or":     TokenTypeOr,
-		"print":  TokenTypePrint,
-		"return": TokenTypeReturn,
-		"super":  TokenTypeSuper,
-		"this":   TokenTypeThis,
-		"true":   TokenTypeTrue,
-		"var":    TokenTypeVar,
-		"while":  TokenTypeWhile,
+		"and":      TokenTypeAnd,
+		"break":    TokenTypeBreak,
+		"catch":    TokenTypeCatch,
+		"class":    TokenTypeClass,
+		"const":    TokenTypeConst,
+		"continue": TokenTypeContinue,
+		"else":     TokenTypeElse,
+		"false":    TokenTypeFalse,
+		"finally":  TokenTypeFinally,
+		"fun":      TokenTypeFun,
+		"for":      TokenTypeFor,
+		"if":       TokenTypeIf,
+		"import":   TokenTypeImport,
+		"in":       TokenTypeIn,
+		"nil":      TokenTypeNil,
+		"or":       TokenTypeOr,
+		"print":    TokenTypePrint,
+		"return":   TokenTypeReturn,
+		"super":    TokenTypeSuper,
+		"this":     TokenTypeThis,
+		"throw":    TokenTypeThrow,
+		"true":     TokenTypeTrue,
+		"try":      TokenTypeTry,
+		"var":      TokenTypeVar,
+		"while":    TokenTypeWhile,
 	}
 
 	TokenNames = map[TokenType]string{
-		TokenTypeNone:         "none",
-		TokenTypeLeftParen:    "leftparen",
-		TokenTypeRightParen:   "rightparen",
-		TokenTypeLeftBrace:    "leftbrace",
-		TokenTypeRightBrace:   "rightbrace",
-		TokenTypeComma:        "comma",
-		TokenTypeDot:          "dot",
-		TokenTypeMinus:        "minus",
-		TokenTypePlus:         "plus",
-		TokenTypeSemicolon:    "semicolon",
-		TokenTypeSlash:        "slash",
-		TokenTypeStar:         "star",
-		TokenTypeBang:         "bang",
-		TokenTypeBangEqual:    "bangequal",
-		TokenTypeEqual:        "equal",
-		TokenTypeEqualEqual:   "equalequal",
-		TokenTypeGreater:      "greater",
-		TokenTypeGreaterEqual: "greaterequal",
-		TokenTypeLess:         "less",
-		TokenTypeLessEqual:    "lessequal",
-		TokenTypeIdentifier:   "identifier",
-		TokenTypeString:       "string",
-		TokenTypeNumber:       "number",
-		TokenTypeComment:      "comment",
-		TokenTypeAnd:          "and",
-		TokenTypeClass:        "class",
-		TokenTypeElse:         "else",
-		TokenTypeFalse:        "false",
-		TokenTypeFun:          "fun",
-		TokenTypeFor:          "for",
-		TokenTypeIf:           "if",
-		TokenTypeNil:          "nil",
-		TokenTypeOr:           "or",
-		TokenTypePrint:        "print",
-		TokenTypeReturn:       "return",
-		TokenTypeSuper:        "super",
-		TokenTypeThis:         "this",
-		TokenTypeTrue:         "true",
-		TokenTypeVar:          "var",
-		TokenTypeWhile:        "while",
-		TokenTypeEOF:          "eof",
+		TokenTypeNone:             "none",
+		TokenTypeLeftParen:        "leftparen",
+		TokenTypeRightParen:       "rightparen",
+		TokenTypeLeftBrace:        "leftbrace",
+		TokenTypeRightBrace:       "rightbrace",
+		TokenTypeLeftBracket:      "leftbracket",
+		TokenTypeRightBracket:     "rightbracket",
+		TokenTypeComma:            "comma",
+		TokenTypeColon:            "colon",
+		TokenTypeDot:              "dot",
+		TokenTypeMinus:            "minus",
+		TokenTypePlus:             "plus",
+		TokenTypeSemicolon:        "semicolon",
+		TokenTypeSlash:            "slash",
+		TokenTypeStar:             "star",
+		TokenTypePercent:          "percent",
+		TokenTypeBang:             "bang",
+		TokenTypeBangEqual:        "bangequal",
+		TokenTypeEqual:            "equal",
+		TokenTypeEqualEqual:       "equalequal",
+		TokenTypeGreater:          "greater",
+		TokenTypeGreaterEqual:     "greaterequal",
+		TokenTypeLess:             "less",
+		TokenTypeLessEqual:        "lessequal",
+		TokenTypeStarStar:         "starstar",
+		TokenTypeTildeSlash:       "tildeslash",
+		TokenTypePlusEqual:        "plusequal",
+		TokenTypeMinusEqual:       "minusequal",
+		TokenTypeStarEqual:        "starequal",
+		TokenTypeSlashEqual:       "slashequal",
+		TokenTypePercentEqual:     "percentequal",
+		TokenTypePlusPlus:         "plusplus",
+		TokenTypeMinusMinus:       "minusminus",
+		TokenTypeQuestion:         "question",
+		TokenTypeQuestionQuestion: "questionquestion",
+		TokenTypeIdentifier:       "identifier",
+		TokenTypeString:           "string",
+		TokenTypeInterpolation:    "interpolation",
+		TokenTypeNumber:           "number",
+		TokenTypeComment:          "comment",
+		TokenTypeAnd:              "and",
+		TokenTypeBreak:            "break",
+		TokenTypeCatch:            "catch",
+		TokenTypeClass:            "class",
+		TokenTypeConst:            "const",
+		TokenTypeContinue:         "continue",
+		TokenTypeElse:             "else",
+		TokenTypeFalse:            "false",
+		TokenTypeFinally:          "finally",
+		TokenTypeFun:              "fun",
+		TokenTypeFor:              "for",
+		TokenTypeIf:               "if",
+		TokenTypeImport:           "import",
+		TokenTypeIn:               "in",
+		TokenTypeNil:              "nil",
+		TokenTypeOr:               "or",
+		TokenTypePrint:            "print",
+		TokenTypeReturn:           "return",
+		TokenTypeSuper:            "super",
+		TokenTypeThis:             "this",
+		TokenTypeThrow:            "throw",
+		TokenTypeTrue:             "true",
+		TokenTypeTry:              "try",
+		TokenTypeVar:              "var",
+		TokenTypeWhile:            "while",
+		TokenTypeEOF:              "eof",
 	}
 )
 
@@ -154,6 +216,18 @@ func isDigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
+func isBinaryDigit(c byte) bool {
+	return c == '0' || c == '1'
+}
+
+func isOctalDigit(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
 func isAlpha(c byte) bool {
 	return c >= 'a' && c <= 'z' ||
 		c >= 'A' && c <= 'Z' || c == '_'
@@ -162,3 +236,26 @@ func isAlpha(c byte) bool {
 func isAlphaNumeric(c byte) bool {
 	return isAlpha(c) || isDigit(c)
 }
+
+// keywords returns every reserved keyword
+func keywords() []string {
+	words := make([]string, 0, len(ReservedKeywords))
+	for word := range ReservedKeywords {
+		words = append(words, word)
+	}
+	return words
+}
+
+// isIdentifier reports whether s would scan as a single identifier
+func isIdentifier(s string) bool {
+	if s == "" || !isAlpha(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isAlphaNumeric(s[i]) {
+			return false
+		}
+	}
+	_, reserved := ReservedKeywords[s]
+	return !reserved
+}
@@ -0,0 +1,104 @@
+package glox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Frame is a Lox function call that was active when a runtime error was
+// raised
+type Frame struct {
+	// Function is the name of the called function, method or class
+	Function string
+	// File is the path of the module making the call, or empty for a program
+	// run without one
+	File string
+	// Pos is the position of the call's closing paren
+	Pos Pos
+}
+
+func (f Frame) String() string {
+	if f.File == "" {
+		return fmt.Sprintf("at %s (line %d)", f.Function, f.Pos.Line+1)
+	}
+	return fmt.Sprintf("at %s (%s:%d)", f.Function, f.File, f.Pos.Line+1)
+}
+
+// frameName names a called function in a stack trace
+func frameName(function Caller) string {
+	switch f := function.(type) {
+	case *DefinedFunc:
+		if f.decl.name.Type == TokenTypeFun {
+			return "anonymous"
+		}
+		return f.decl.name.Lexeme
+	case *LoxClass:
+		return f.name
+	}
+	return function.String()
+}
+
+// definingFile returns the path of the module that declares a function
+func definingFile(function Caller) string {
+	switch f := function.(type) {
+	case *DefinedFunc:
+		return moduleOf(f.closure).path
+	case *LoxClass:
+		if initializer, ok := f.findMethod("init"); ok {
+			return moduleOf(initializer.closure).path
+		}
+	}
+	return ""
+}
+
+// addFrame records a call to function that a runtime error or thrown value
+// unwound through. Errors unwind from the innermost call outwards, so traces
+// are built innermost first. The first call unwound through is the one whose
+// body raised the error, so it determines the error's file.
+func addFrame(r any, function Caller, frame Frame) any {
+	switch v := r.(type) {
+	case *RuntimeError:
+		if len(v.Trace) == 0 {
+			v.File = definingFile(function)
+		}
+		v.Trace = append(v.Trace, frame)
+	case thrownValue:
+		if len(v.trace) == 0 {
+			v.file = definingFile(function)
+		}
+		v.trace = append(v.trace, frame)
+		return v
+	}
+	return r
+}
+
+// StackTrace formats the error's trace innermost first, one frame per line.
+// Runs of frames for the same call, as in deep recursion, are collapsed into
+// one. At most limit lines of frames are shown, or all of them if limit is not
+// positive.
+func (e *RuntimeError) StackTrace(limit int) string {
+	lines := []string{}
+	for i := 0; i < len(e.Trace); {
+		if limit > 0 && len(lines) >= limit {
+			lines = append(lines, fmt.Sprintf("... %d more frames", len(e.Trace)-i))
+			break
+		}
+		frame := e.Trace[i]
+		repeats := 0
+		for i++; i < len(e.Trace) && sameCall(e.Trace[i], frame); i++ {
+			repeats++
+		}
+		line := frame.String()
+		if repeats > 0 {
+			line += fmt.Sprintf(" [repeated %d more times]", repeats)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sameCall reports whether two frames are calls to the same function from the
+// same line
+func sameCall(a, b Frame) bool {
+	return a.Function == b.Function && a.File == b.File && a.Pos.Line == b.Pos.Line
+}
@@ -1,35 +1,63 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"interpreter/glox"
 	"os"
 )
 
+var (
+	traceDepth  = flag.Int("trace-depth", 20, "maximum number of call frames to print for a runtime error, or 0 for all")
+	diagnostics = flag.String("diagnostics", "text", "format of error output on stderr: text, json or sarif")
+)
+
 func main() {
 	flag.Parse()
 	if flag.NArg() < 1 {
 		flag.Usage()
 		return
 	}
+	switch *diagnostics {
+	case "text", "json", "sarif":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown diagnostics format %q\n", *diagnostics)
+		flag.Usage()
+		os.Exit(64)
+	}
 	filename := flag.Arg(0)
 	// todo: REPL?
-	if err := runFile(filename); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	source, err := os.ReadFile(filename)
+	if err == nil {
+		err = run(filename, source)
+	}
+	if *diagnostics != "text" {
+		// Machine-readable output is written even for a successful run, so
+		// that tools can always parse it
+		if err := writeDiagnostics(filename, source, err); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	}
-}
-
-func runFile(filename string) error {
-	fBytes, err := os.ReadFile(filename)
 	if err != nil {
-		return err
+		if *diagnostics == "text" {
+			report(filename, source, err)
+		}
+		var rerr *glox.RuntimeError
+		var perrs glox.ParseErrors
+		var serr *glox.ScanError
+		var reserr *glox.ResolveError
+		switch {
+		case errors.As(err, &perrs), errors.As(err, &serr), errors.As(err, &reserr):
+			os.Exit(65)
+		case errors.As(err, &rerr):
+			os.Exit(70)
+		}
+		os.Exit(1)
 	}
-	return run(fBytes)
 }
 
-func run(source []byte) error {
+func run(filename string, source []byte) error {
 	scanner := glox.NewScanner(source)
 	tokens, err := scanner.ScanTokens()
 	if err != nil {
@@ -37,7 +65,53 @@ func run(source []byte) error {
 	}
 	// glox.NewParser(tokens).PrintAST()
 	parser := glox.NewParser(tokens)
-	env := glox.NewEnvironment(nil)
-	parser.Execute(env)
-	return nil
+	env := glox.NewModuleEnvironment(filename)
+	return parser.Execute(env)
+}
+
+// report prints err to stderr with the source lines it refers to, in color if
+// stderr is a terminal
+func report(filename string, source []byte, err error) {
+	color := isTerminal(os.Stderr) && os.Getenv("NO_COLOR") == ""
+	sources := sourceFunc(filename, source)
+	for _, diag := range glox.Diagnostics(err, filename) {
+		diag.Render(os.Stderr, sources(diag.File), color)
+	}
+	var rerr *glox.RuntimeError
+	if errors.As(err, &rerr) && len(rerr.Trace) > 0 {
+		fmt.Fprintln(os.Stderr, rerr.StackTrace(*traceDepth))
+	}
+}
+
+// writeDiagnostics writes the errors in err, if any, to stderr in the format
+// chosen with -diagnostics
+func writeDiagnostics(filename string, source []byte, err error) error {
+	diags := []glox.Diagnostic{}
+	if err != nil {
+		diags = glox.Diagnostics(err, filename)
+	}
+	if *diagnostics == "sarif" {
+		return glox.WriteSARIF(os.Stderr, diags, sourceFunc(filename, source))
+	}
+	return glox.WriteJSON(os.Stderr, diags, sourceFunc(filename, source))
+}
+
+// sourceFunc returns the source of the file being run, or reads the source of
+// an imported module that a diagnostic refers to
+func sourceFunc(filename string, source []byte) glox.SourceFunc {
+	return func(file string) []byte {
+		if file == filename {
+			return source
+		}
+		other, err := os.ReadFile(file)
+		if err != nil {
+			return nil
+		}
+		return other
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }